@@ -32,6 +32,7 @@ const (
 	cacheUseRistrettoKey          = "cacheUseRistretto"
 	cacheMaxSizeKey               = "cacheMaxSize"
 	cacheNumCountersKey           = "cacheNumCounters"
+	cacheRevalidationWindowKey    = "cacheRevalidationWindow"
 	retryFailedAfterKey           = "retryFailedAfter"
 	maxURLsInRequestKey           = "maxURLsInRequest"
 	requestsPerSecondPerDomainKey = "requestsPerSecondPerDomain"
@@ -42,14 +43,33 @@ const (
 	httpClientMapKey        = "HTTPClient."
 	proxyKey                = "proxy"
 	pacScriptURLKey         = "pacScriptURL"
+	pacScriptRefreshKey     = "pacScriptRefreshInterval"
+	proxyUsernameKey        = "proxyUsername"
+	proxyPasswordKey        = "proxyPassword"
 	maxRedirectsCountKey    = "maxRedirectsCount"
 	limitBodyToNBytesKey    = "limitBodyToNBytes"
+	rangeFallbackBytesKey   = "rangeFallbackBytes"
+	maxIdleConnsPerHostKey  = "maxIdleConnsPerHost"
+	idleConnTimeoutKey      = "idleConnTimeout"
 	timeoutSecondsKey       = "timeoutSeconds"
+	connectTimeoutKey       = "connectTimeout"
+	tlsHandshakeTimeoutKey  = "tlsHandshakeTimeout"
+	respHeaderTimeoutKey    = "responseHeaderTimeout"
+	bodyReadTimeoutKey      = "bodyReadTimeout"
 	userAgentKey            = "userAgent"
 	browserUserAgentKey     = "browserUserAgent"
 	acceptHeaderKey         = "acceptHeader"
 	skipCertificateCheckKey = "skipCertificateCheck"
 	enableRequestTracingKey = "enableRequestTracing"
+	impersonateProfileKey   = "impersonateProfile"
+	retryAttemptsKey        = "retryAttempts"
+	retryBackoffKey         = "retryBackoff"
+	retryMaxBackoffKey      = "retryMaxBackoff"
+	inspectCertificatesKey  = "inspectCertificates"
+	certificateExpiryKey    = "certificateExpiryWarningDays"
+	dnsServerKey            = "dnsServer"
+	dnsOverHTTPSURLKey      = "dnsOverHTTPSURL"
+	resolveKey              = "resolve"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -84,10 +104,16 @@ func defaultConfigHome() string {
 }
 
 func registerHTTPClientPersistentFlags() {
-	rootCmd.PersistentFlags().StringP(proxyKey, "", "", "HTTP client: proxy server to use, e.g. http://myproxy:8080")
+	rootCmd.PersistentFlags().StringP(proxyKey, "", "", "HTTP client: proxy server to use, e.g. http://myproxy:8080 or socks5://myproxy:1080")
 	_ = viper.BindPFlag(proxyKey, rootCmd.PersistentFlags().Lookup(proxyKey))
-	rootCmd.PersistentFlags().StringP(pacScriptURLKey, "", "", "HTTP client: PAC script URL, e.g. http://myproxy/proxy.pac")
+	rootCmd.PersistentFlags().StringP(pacScriptURLKey, "", "", "HTTP client: PAC script URL, e.g. http://myproxy/proxy.pac or file:///etc/proxy.pac")
 	_ = viper.BindPFlag(pacScriptURLKey, rootCmd.PersistentFlags().Lookup(pacScriptURLKey))
+	rootCmd.PersistentFlags().String(pacScriptRefreshKey, "5m", "HTTP client: interval to check the PAC script for changes at, never if 0 (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(pacScriptRefreshKey, rootCmd.PersistentFlags().Lookup(pacScriptRefreshKey))
+	rootCmd.PersistentFlags().String(proxyUsernameKey, "", "HTTP client: proxy username, may reference a secret via env:<VARIABLE> or file:<path>")
+	_ = viper.BindPFlag(proxyUsernameKey, rootCmd.PersistentFlags().Lookup(proxyUsernameKey))
+	rootCmd.PersistentFlags().String(proxyPasswordKey, "", "HTTP client: proxy password, to be referenced via env:<VARIABLE> or file:<path>")
+	_ = viper.BindPFlag(proxyPasswordKey, rootCmd.PersistentFlags().Lookup(proxyPasswordKey))
 
 	rootCmd.PersistentFlags().Uint(maxRedirectsCountKey, 15, "HTTP client: maximum number of redirects to follow")
 	_ = viper.BindPFlag(httpClientMapKey+maxRedirectsCountKey, rootCmd.PersistentFlags().Lookup(maxRedirectsCountKey))
@@ -105,6 +131,38 @@ func registerHTTPClientPersistentFlags() {
 	_ = viper.BindPFlag(httpClientMapKey+enableRequestTracingKey, rootCmd.PersistentFlags().Lookup(enableRequestTracingKey))
 	rootCmd.PersistentFlags().Uint(limitBodyToNBytesKey, 0, "HTTP client: maximum number of bytes to read from the body when searching for patterns. Unlimited if 0!")
 	_ = viper.BindPFlag(httpClientMapKey+limitBodyToNBytesKey, rootCmd.PersistentFlags().Lookup(limitBodyToNBytesKey))
+	rootCmd.PersistentFlags().Uint(rangeFallbackBytesKey, 1024, "HTTP client: number of bytes to request via a ranged GET if a HEAD request fails, and no body patterns are searched")
+	_ = viper.BindPFlag(httpClientMapKey+rangeFallbackBytesKey, rootCmd.PersistentFlags().Lookup(rangeFallbackBytesKey))
+	rootCmd.PersistentFlags().Uint(maxIdleConnsPerHostKey, 4, "HTTP client: maximum number of kept-alive idle connections per host")
+	_ = viper.BindPFlag(httpClientMapKey+maxIdleConnsPerHostKey, rootCmd.PersistentFlags().Lookup(maxIdleConnsPerHostKey))
+	rootCmd.PersistentFlags().String(idleConnTimeoutKey, "90s", "HTTP client: time after which idle connections are closed (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(httpClientMapKey+idleConnTimeoutKey, rootCmd.PersistentFlags().Lookup(idleConnTimeoutKey))
+	rootCmd.PersistentFlags().String(connectTimeoutKey, "30s", "HTTP client: maximum time to resolve the host name and connect, 0 for no limit (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(httpClientMapKey+connectTimeoutKey, rootCmd.PersistentFlags().Lookup(connectTimeoutKey))
+	rootCmd.PersistentFlags().String(tlsHandshakeTimeoutKey, "10s", "HTTP client: maximum time of the TLS handshake, 0 for no limit (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(httpClientMapKey+tlsHandshakeTimeoutKey, rootCmd.PersistentFlags().Lookup(tlsHandshakeTimeoutKey))
+	rootCmd.PersistentFlags().String(respHeaderTimeoutKey, "0", "HTTP client: maximum time to wait for the response headers after sending the request, 0 for no limit (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(httpClientMapKey+respHeaderTimeoutKey, rootCmd.PersistentFlags().Lookup(respHeaderTimeoutKey))
+	rootCmd.PersistentFlags().String(bodyReadTimeoutKey, "0", "HTTP client: maximum time to read a response body, after which the body read so far is used, 0 for no limit (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(httpClientMapKey+bodyReadTimeoutKey, rootCmd.PersistentFlags().Lookup(bodyReadTimeoutKey))
+	rootCmd.PersistentFlags().String(impersonateProfileKey, "", "HTTP client: browser profile to impersonate for all domains, e.g. chrome, firefox, safari")
+	_ = viper.BindPFlag(httpClientMapKey+impersonateProfileKey, rootCmd.PersistentFlags().Lookup(impersonateProfileKey))
+	rootCmd.PersistentFlags().Uint(retryAttemptsKey, 0, "HTTP client: number of additional attempts on 429 and 503 responses")
+	_ = viper.BindPFlag(httpClientMapKey+retryAttemptsKey, rootCmd.PersistentFlags().Lookup(retryAttemptsKey))
+	rootCmd.PersistentFlags().String(retryBackoffKey, "1s", "HTTP client: initial backoff between attempts, doubled for each attempt (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(httpClientMapKey+retryBackoffKey, rootCmd.PersistentFlags().Lookup(retryBackoffKey))
+	rootCmd.PersistentFlags().String(retryMaxBackoffKey, "30s", "HTTP client: maximum backoff between attempts. Longer Retry-After delays are not waited for (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(httpClientMapKey+retryMaxBackoffKey, rootCmd.PersistentFlags().Lookup(retryMaxBackoffKey))
+	rootCmd.PersistentFlags().Bool(inspectCertificatesKey, false, "HTTP client: report the peer certificates of HTTPS checks")
+	_ = viper.BindPFlag(httpClientMapKey+inspectCertificatesKey, rootCmd.PersistentFlags().Lookup(inspectCertificatesKey))
+	rootCmd.PersistentFlags().Uint(certificateExpiryKey, 14, "HTTP client: warn about certificates expiring in less than the given number of days")
+	_ = viper.BindPFlag(httpClientMapKey+certificateExpiryKey, rootCmd.PersistentFlags().Lookup(certificateExpiryKey))
+	rootCmd.PersistentFlags().String(dnsServerKey, "", "HTTP client: DNS server to resolve host names with instead of the system resolver, e.g. 10.0.0.53:53")
+	_ = viper.BindPFlag(httpClientMapKey+dnsServerKey, rootCmd.PersistentFlags().Lookup(dnsServerKey))
+	rootCmd.PersistentFlags().String(dnsOverHTTPSURLKey, "", "HTTP client: DNS-over-HTTPS endpoint to resolve host names with, e.g. https://dns.example.com/dns-query")
+	_ = viper.BindPFlag(httpClientMapKey+dnsOverHTTPSURLKey, rootCmd.PersistentFlags().Lookup(dnsOverHTTPSURLKey))
+	rootCmd.PersistentFlags().StringSlice(resolveKey, nil, "HTTP client: static host:port:address[,address] resolution overrides, as with curl --resolve")
+	_ = viper.BindPFlag(httpClientMapKey+resolveKey, rootCmd.PersistentFlags().Lookup(resolveKey))
 }
 
 func registerCachePersistentFlags() {
@@ -118,6 +176,8 @@ func registerCachePersistentFlags() {
 	_ = viper.BindPFlag(cacheMaxSizeKey, rootCmd.PersistentFlags().Lookup(cacheMaxSizeKey))
 	rootCmd.PersistentFlags().Int64(cacheNumCountersKey, 10_000_000, "Number of 4-bit access counters. Set at approx 10x max unique expected URLs (when cacheUseRistretto enabled)")
 	_ = viper.BindPFlag(cacheNumCountersKey, rootCmd.PersistentFlags().Lookup(cacheNumCountersKey))
+	rootCmd.PersistentFlags().String(cacheRevalidationWindowKey, "0", "Keep the ok URL check results with an ETag or Last-Modified validator for <interval> after their expiration, and revalidate them via conditional requests. Disabled if 0 (in ns/us/ms/s/m/h)")
+	_ = viper.BindPFlag(cacheRevalidationWindowKey, rootCmd.PersistentFlags().Lookup(cacheRevalidationWindowKey))
 }
 
 func registerServicePersistentFlags() {
@@ -0,0 +1,122 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	netUrl "net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+// fragmentToValidate returns the document URL and the fragment to look up in it, if the URL should be validated
+func fragmentToValidate(urlToCheck string) (string, string, bool) {
+	u, err := netUrl.Parse(urlToCheck)
+	if err != nil || u.Fragment == "" {
+		return "", "", false
+	}
+	fragment := u.Fragment
+	// "#top" scrolls to the top of the document, and "#/..." or "#!..." are typically client-side routes
+	if strings.EqualFold(fragment, "top") || strings.HasPrefix(fragment, "/") || strings.HasPrefix(fragment, "!") {
+		return "", "", false
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	return u.String(), fragment, true
+}
+
+func (c *URLCheckerClient) validateAnchor(ctx context.Context, urlToCheck string, client *resty.Client, res *URLCheckResult) *URLCheckResult {
+	if !c.settings.CheckAnchors || res.Status != Ok {
+		return res
+	}
+	documentURL, fragment, ok := fragmentToValidate(urlToCheck)
+	if !ok {
+		return res
+	}
+
+	anchors, err := c.anchors.get(documentURL, func() ([]string, error) {
+		return c.fetchAnchors(ctx, documentURL, client)
+	})
+	if err != nil {
+		// the document itself is reachable: not being able to validate the anchor is not an error
+		log.Debug().Err(err).Msgf("Could not validate the anchor of %v", sanitizeUserLogInput(urlToCheck))
+		return res
+	}
+	// non-HTML documents have no anchors to validate against
+	if anchors == nil || slices.Contains(anchors, fragment) {
+		return res
+	}
+
+	result := *res
+	result.Status = BrokenAnchor
+	result.Error = fmt.Errorf("anchor '%v' not found in '%v'", fragment, documentURL)
+	result.AvailableAnchors = anchors
+	return &result
+}
+
+func (c *URLCheckerClient) fetchAnchors(ctx context.Context, documentURL string, client *resty.Client) ([]string, error) {
+	response, err := c.newRequest(ctx, client, documentURL, c.settings.BrowserUserAgent).
+		SetDoNotParseResponse(true).
+		Get(documentURL)
+	if err != nil {
+		return nil, err
+	}
+	body := c.bodyOf(response)
+	if body == nil {
+		return nil, fmt.Errorf("no response body")
+	}
+	defer func() { _ = body.Close() }()
+
+	if response.StatusCode() >= 300 {
+		return nil, fmt.Errorf("%v status on url '%v'", response.StatusCode(), documentURL)
+	}
+	if !isHTML(response.Header()) {
+		return nil, nil
+	}
+	return parseAnchors(c.limitedReader(body))
+}
+
+func isHTML(header http.Header) bool {
+	contentType := header.Get("Content-Type")
+	return contentType == "" || strings.Contains(contentType, "html")
+}
+
+// parseAnchors collects the id attributes of all elements and the name attributes of <a> elements
+func parseAnchors(input io.Reader) ([]string, error) {
+	anchors := []string{}
+	tokenizer := html.NewTokenizer(input)
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, err
+			}
+			return anchors, nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			anchors = appendAnchorsOf(tokenizer.Token(), anchors)
+		}
+	}
+}
+
+func appendAnchorsOf(token html.Token, anchors []string) []string {
+	for _, attr := range token.Attr {
+		if attr.Val == "" {
+			continue
+		}
+		if attr.Key == "id" || (attr.Key == "name" && token.Data == "a") {
+			anchors = append(anchors, attr.Val)
+		}
+	}
+	return anchors
+}
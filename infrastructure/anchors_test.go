@@ -0,0 +1,77 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const anchorTestPage = `<html><body>
+<h1 id="intro">Intro</h1>
+<a name="legacy"></a>
+<input name="not-an-anchor">
+<h2 id="usage">Usage</h2>
+</body></html>`
+
+func TestParseAnchors(t *testing.T) {
+	anchors, err := parseAnchors(strings.NewReader(anchorTestPage))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"intro", "legacy", "usage"}, anchors)
+}
+
+func TestFragmentToValidate(t *testing.T) {
+	documentURL, fragment, ok := fragmentToValidate("https://docs.example.com/page?a=1#install")
+	assert.True(t, ok)
+	assert.Equal(t, "https://docs.example.com/page?a=1", documentURL)
+	assert.Equal(t, "install", fragment)
+
+	for _, u := range []string{"https://a.com/page", "https://a.com/#top", "https://a.com/#/route", "https://a.com/#!route"} {
+		_, _, ok = fragmentToValidate(u)
+		assert.False(t, ok, u)
+	}
+}
+
+func TestValidatingAnchors(t *testing.T) {
+	var gets int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			atomic.AddInt32(&gets, 1)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte(anchorTestPage))
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"#missing")
+	assert.Equal(t, Ok, res.Status, "anchors should not be validated by default")
+
+	viper.Set("checkAnchors", true)
+	c := NewURLCheckerClient()
+	res = c.CheckURL(context.Background(), ts.URL+"#usage")
+	assert.Equal(t, Ok, res.Status)
+
+	res = c.CheckURL(context.Background(), ts.URL+"#legacy")
+	assert.Equal(t, Ok, res.Status)
+
+	res = c.CheckURL(context.Background(), ts.URL+"#missing")
+	assert.Equal(t, BrokenAnchor, res.Status)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotNil(t, res.Error)
+	assert.Equal(t, []string{"intro", "legacy", "usage"}, res.AvailableAnchors)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&gets), "the document should have been fetched only once")
+}
@@ -0,0 +1,162 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"fmt"
+	"io"
+	"regexp/syntax"
+)
+
+const bodyPatternChunkBytes = 32 * 1024
+
+// bodyPatternOverlapBytes is kept from the previous chunk, so that matches spanning chunk boundaries are found,
+// as long as they are not longer than this
+const bodyPatternOverlapBytes = 4 * 1024
+
+// bodyPatternEffect determines how a found body pattern affects the result
+type bodyPatternEffect string
+
+const (
+	bodyPatternReport           bodyPatternEffect = "report"
+	bodyPatternMarkBroken       bodyPatternEffect = "mark_broken"
+	bodyPatternMarkRequiresAuth bodyPatternEffect = "mark_requires_auth"
+)
+
+func mustBeBodyPatternEffect(effect string) bodyPatternEffect {
+	switch e := bodyPatternEffect(effect); e {
+	case "":
+		return bodyPatternReport
+	case bodyPatternReport, bodyPatternMarkBroken, bodyPatternMarkRequiresAuth:
+		return e
+	default:
+		panic(fmt.Errorf("unknown body pattern effect: %v", effect))
+	}
+}
+
+// severity orders the effects, the most severe one found wins
+func (e bodyPatternEffect) severity() int {
+	switch e {
+	case bodyPatternMarkBroken:
+		return 2
+	case bodyPatternMarkRequiresAuth:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (p bodyPattern) appliesTo(domain string) bool {
+	return p.domain == nil || p.domain.Match(domain)
+}
+
+// textAnchorsOf tells whether the regex refers to the beginning or the end of the text,
+// which are only meaningful in the first and in the last chunk of a body
+func textAnchorsOf(regex string) (beginning bool, end bool) {
+	re, err := syntax.Parse(regex, syntax.Perl)
+	if err != nil {
+		return false, false
+	}
+	var walk func(re *syntax.Regexp)
+	walk = func(re *syntax.Regexp) {
+		switch re.Op {
+		case syntax.OpBeginText:
+			beginning = true
+		case syntax.OpEndText:
+			end = true
+		}
+		for _, sub := range re.Sub {
+			walk(sub)
+		}
+	}
+	walk(re)
+	return beginning, end
+}
+
+// canMatchIn tells whether the pattern can match in a window at the given position of the body
+func (p bodyPattern) canMatchIn(first, last bool) bool {
+	return (first || !p.anchoredAtBeginning) && (last || !p.anchoredAtEnd)
+}
+
+// matchBodyPatterns streams the body in chunks through the patterns applying to the domain, keeping
+// an overlap window of the previous chunk. Reading stops as soon as all patterns are either found,
+// or ruled out. Returns whether each pattern was found, and the number of bytes read.
+func matchBodyPatterns(patterns []bodyPattern, domain string, body io.Reader) ([]bool, int64) {
+	found := make([]bool, len(patterns))
+	// settled patterns are either found, or ruled out
+	settled := make([]bool, len(patterns))
+	pending := 0
+	for i, pattern := range patterns {
+		settled[i] = !pattern.appliesTo(domain)
+		if !settled[i] {
+			pending++
+		}
+	}
+	if pending == 0 {
+		return found, 0
+	}
+
+	var inspected int64
+	window := make([]byte, 0, bodyPatternOverlapBytes+bodyPatternChunkBytes)
+	chunk := make([]byte, bodyPatternChunkBytes)
+	first := true
+	for pending > 0 {
+		n, err := io.ReadFull(body, chunk)
+		inspected += int64(n)
+		window = append(window, chunk[:n]...)
+		// a failed read is treated as the end of the body
+		last := err != nil
+		for i, pattern := range patterns {
+			if settled[i] {
+				continue
+			}
+			found[i] = pattern.canMatchIn(first, last) && pattern.pattern.Match(window)
+			// patterns anchored at the beginning of the body can only match in the first chunk
+			settled[i] = found[i] || (pattern.anchoredAtBeginning && !pattern.anchoredAtEnd)
+			if settled[i] {
+				pending--
+			}
+		}
+		if last {
+			break
+		}
+		first = false
+		if len(window) > bodyPatternOverlapBytes {
+			window = append(window[:0], window[len(window)-bodyPatternOverlapBytes:]...)
+		}
+	}
+	return found, inspected
+}
+
+// searchForBodyPatterns reports the patterns found in the body, and applies the most severe effect
+// of the found patterns to successful responses
+func (c *URLCheckerClient) searchForBodyPatterns(urlToCheck string, patterns []bodyPattern, res *URLCheckResult, body io.Reader) *URLCheckResult {
+	found, inspected := matchBodyPatterns(patterns, DomainOf(urlToCheck), body)
+	res.BodyBytesInspected = inspected
+	var markedBy *bodyPattern
+	for i, pattern := range patterns {
+		if !found[i] {
+			continue
+		}
+		res.BodyPatternsFound = append(res.BodyPatternsFound, pattern.name)
+		if pattern.effect.severity() > 0 && (markedBy == nil || pattern.effect.severity() > markedBy.effect.severity()) {
+			markedBy = &patterns[i]
+		}
+	}
+	if markedBy == nil || res.Status != Ok {
+		return res
+	}
+
+	res.Error = fmt.Errorf("body pattern '%v' found on url '%v'", markedBy.name, urlToCheck)
+	if markedBy.effect == bodyPatternMarkBroken {
+		res.Status = Broken
+		res.brokenBecause = "body_pattern: " + markedBy.name
+	} else {
+		res.Status = RequiresAuth
+	}
+	return res
+}
@@ -0,0 +1,167 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyPatternEffects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/archived":
+			_, _ = fmt.Fprint(w, "<html>This repository has been archived. Please sign in</html>")
+		case "/login":
+			_, _ = fmt.Fprint(w, "<html>Please sign in</html>")
+		default:
+			_, _ = fmt.Fprint(w, "<html>Welcome</html>")
+		}
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{
+		{Name: "login", Regex: "sign in", Effect: "mark_requires_auth"},
+		{Name: "archived", Regex: "has been archived", Effect: "mark_broken"},
+		{Name: "welcome", Regex: "Welcome"},
+	})
+	c := NewURLCheckerClient()
+
+	tests := []struct {
+		path          string
+		status        URLCheckStatus
+		patternsFound []string
+		brokenBecause string
+	}{
+		{"/", Ok, []string{"welcome"}, ""},
+		{"/login", RequiresAuth, []string{"login"}, "requires_auth"},
+		{"/archived", Broken, []string{"login", "archived"}, "body_pattern: archived"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ResetGlobalStats()
+			res := c.CheckURL(context.Background(), ts.URL+tt.path)
+			assert.Equal(t, tt.status, res.Status)
+			assert.Equal(t, http.StatusOK, res.Code)
+			assert.Equal(t, tt.patternsFound, res.BodyPatternsFound)
+			if tt.brokenBecause != "" {
+				assert.Contains(t, res.Error.Error(), "body pattern")
+				assert.Equal(t, map[string]int64{tt.brokenBecause: 1}, GlobalStats().GetDomainStats().DomainStats["127.0.0.1"].BrokenBecause)
+			}
+		})
+	}
+}
+
+func TestBodyPatternsScopedToDomains(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "Page not found")
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{
+		{Name: "not found", Regex: "not found", Effect: "mark_broken", Domain: "*.example.com"},
+	})
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, res.Status)
+	assert.Empty(t, res.BodyPatternsFound)
+
+	viper.Set("bodyPatterns", []BodyPatternConfig{
+		{Name: "not found", Regex: "not found", Effect: "mark_broken", Domain: "127.0.0.*"},
+	})
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Broken, res.Status)
+	assert.Equal(t, []string{"not found"}, res.BodyPatternsFound)
+}
+
+func TestUnknownBodyPatternEffects(t *testing.T) {
+	setUpViperTestConfiguration()
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{
+		{Name: "x", Regex: "x", Effect: "mark_fancy"},
+	})
+	assert.Panics(t, func() {
+		NewURLCheckerClient()
+	})
+}
+
+func TestStreamingBodyPatterns(t *testing.T) {
+	setUpViperTestConfiguration()
+	viper.Set("searchForBodyPatterns", true)
+	matcherOf := func(patterns ...BodyPatternConfig) *URLCheckerClient {
+		viper.Set("bodyPatterns", patterns)
+		return NewURLCheckerClient()
+	}
+	filler := strings.Repeat("x", 10*bodyPatternChunkBytes)
+
+	t.Run("reading stops once all patterns are found", func(t *testing.T) {
+		c := matcherOf(BodyPatternConfig{Name: "start", Regex: "start"})
+		found, inspected := matchBodyPatterns(c.settings.BodyPatterns, "example.com", strings.NewReader("start"+filler))
+		assert.Equal(t, []bool{true}, found)
+		assert.Equal(t, int64(bodyPatternChunkBytes), inspected)
+	})
+
+	t.Run("matches spanning chunk boundaries are found", func(t *testing.T) {
+		c := matcherOf(BodyPatternConfig{Name: "needle", Regex: "needle"})
+		body := filler[:bodyPatternChunkBytes-3] + "needle" + filler
+		found, inspected := matchBodyPatterns(c.settings.BodyPatterns, "example.com", strings.NewReader(body))
+		assert.Equal(t, []bool{true}, found)
+		assert.Equal(t, int64(2*bodyPatternChunkBytes), inspected)
+	})
+
+	t.Run("patterns anchored at the beginning are ruled out after the first chunk", func(t *testing.T) {
+		c := matcherOf(BodyPatternConfig{Name: "doctype", Regex: "^<!DOCTYPE"})
+		found, inspected := matchBodyPatterns(c.settings.BodyPatterns, "example.com", strings.NewReader(filler+"<!DOCTYPE"))
+		assert.Equal(t, []bool{false}, found)
+		assert.Equal(t, int64(bodyPatternChunkBytes), inspected)
+	})
+
+	t.Run("patterns anchored at the end only match at the end of the body", func(t *testing.T) {
+		c := matcherOf(BodyPatternConfig{Name: "end", Regex: "x$"})
+		found, inspected := matchBodyPatterns(c.settings.BodyPatterns, "example.com", strings.NewReader(filler+"y"))
+		assert.Equal(t, []bool{false}, found)
+		assert.Equal(t, int64(len(filler)+1), inspected)
+	})
+
+	t.Run("nothing is read without patterns in scope", func(t *testing.T) {
+		c := matcherOf(BodyPatternConfig{Name: "scoped", Regex: "x", Domain: "*.example.org"})
+		found, inspected := matchBodyPatterns(c.settings.BodyPatterns, "example.com", strings.NewReader(filler))
+		assert.Equal(t, []bool{false}, found)
+		assert.Zero(t, inspected)
+	})
+
+	t.Run("the body of a failing stream is searched up to the failure", func(t *testing.T) {
+		c := matcherOf(BodyPatternConfig{Name: "d", Regex: "d"}, BodyPatternConfig{Name: "a", Regex: "a"})
+		found, inspected := matchBodyPatterns(c.settings.BodyPatterns, "example.com", faultyReaderOf("abc,d", 3))
+		assert.Equal(t, []bool{false, true}, found)
+		assert.Equal(t, int64(3), inspected)
+	})
+}
+
+func TestBodyBytesInspectedAreReported(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "<html>Welcome</html>")
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{{Name: "missing", Regex: "missing"}})
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, res.Status)
+	assert.Equal(t, int64(len("<html>Welcome</html>")), res.BodyBytesInspected)
+}
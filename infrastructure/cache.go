@@ -78,13 +78,13 @@ func newRistrettoCache(settings cacheSettings) *ristrettoCache {
 	}
 	return &ristrettoCache{
 		cache:             rc,
-		defaultExpiration: settings.cacheExpirationInterval,
+		defaultExpiration: settings.entryLifetime(),
 	}
 }
 
 func newDefaultCache(settings cacheSettings) *defaultCache {
 	return &defaultCache{
-		cache: cache.New(settings.cacheExpirationInterval, settings.cacheCleanupInterval),
+		cache: cache.New(settings.entryLifetime(), settings.cacheCleanupInterval),
 	}
 }
 
@@ -10,6 +10,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/patrickmn/go-cache"
 	"github.com/rs/zerolog/log"
 
 	"github.com/spf13/viper"
@@ -20,13 +21,21 @@ const defaultCacheCleanupInterval = 48 * time.Hour
 const defaultRetryFailedAfter = 30 * time.Second
 const defaultCacheMaxSize int64 = 1e9
 const defaultCacheNumCounters int64 = 10_000_000
+const defaultContentHashRetention = 30 * 24 * time.Hour
 
 // CachedURLChecker wraps a concurrency-limited URL checker
 type CachedURLChecker struct {
 	cache                   resultCache
 	retryFailedAfterSeconds int64
+	expirationSeconds       int64
+	// revalidate keeps the results past their expiration, for the ok ones to be revalidated via conditional requests
+	revalidate bool
+	// contentHashes keeps the content hash of each URL longer than its result, nil if content changes are not detected
+	contentHashes *cache.Cache
 
 	ccLimitedChecker *CCLimitedURLChecker
+	// cacheKeyOf keeps the results of checks with credentials apart from anonymous ones
+	cacheKeyOf func(url string) string
 }
 
 type cacheSettings struct {
@@ -36,16 +45,29 @@ type cacheSettings struct {
 	cacheMaxSize            int64
 	cacheNumCounters        int64
 	retryFailedAfter        time.Duration
+	cacheRevalidationWindow time.Duration
+	detectContentChanges    bool
+	contentHashRetention    time.Duration
+}
+
+// entryLifetime is the time the results are kept for, including the revalidation window
+func (s cacheSettings) entryLifetime() time.Duration {
+	return s.cacheExpirationInterval + s.cacheRevalidationWindow
 }
 
 // NewCachedURLChecker creates a new cached URL checker instance
 func NewCachedURLChecker() *CachedURLChecker {
 	settings := fetchCachedURLCheckerSettings()
+	ccLimitedChecker := NewCCLimitedURLChecker()
 
 	checker := CachedURLChecker{
 		cache:                   newCache(settings),
-		ccLimitedChecker:        NewCCLimitedURLChecker(),
+		ccLimitedChecker:        ccLimitedChecker,
 		retryFailedAfterSeconds: int64(settings.retryFailedAfter.Seconds()),
+		expirationSeconds:       int64(settings.cacheExpirationInterval.Seconds()),
+		revalidate:              settings.cacheRevalidationWindow > 0,
+		contentHashes:           newContentHashCache(settings),
+		cacheKeyOf:              ccLimitedChecker.cacheKeyOf,
 	}
 	return &checker
 }
@@ -88,30 +110,81 @@ func fetchCachedURLCheckerSettings() cacheSettings {
 	}
 
 	s.retryFailedAfter = viperDuration("retryFailedAfter", defaultRetryFailedAfter)
+	s.cacheRevalidationWindow = viperDuration("cacheRevalidationWindow", 0)
+	s.detectContentChanges = viper.GetBool("detectContentChanges")
+	if s.detectContentChanges {
+		s.contentHashRetention = viperDuration("contentHashRetention", defaultContentHashRetention)
+	}
 	return s
 }
 
 // CheckURL checks the desired URL
 func (c *CachedURLChecker) CheckURL(ctx context.Context, url string) *URLCheckResult {
-	res, found := c.cache.Get(url)
+	key := c.cacheKeyOf(url)
+	cached, found := c.cache.Get(key)
 
-	if found && c.shouldTakeCachedResult(res) {
+	if found && c.shouldTakeCachedResult(cached) {
 		GlobalStats().OnCacheHit()
 		// failures could have been temporary -> retry a URL after some time
-		return res
+		return cached
 	}
 	GlobalStats().OnCacheMiss()
 
+	revalidating := found && c.revalidate && cached.canBeRevalidated()
+	if revalidating {
+		ctx = withRevalidation(ctx, cached)
+	}
+
 	// otherwise, do the check & store
-	res = c.ccLimitedChecker.CheckURL(ctx, url)
+	res := c.ccLimitedChecker.CheckURL(ctx, url)
+	if revalidating && res.notModified {
+		GlobalStats().OnCacheRevalidation()
+		res = cached.revalidatedBy(res)
+	}
+	res = c.withContentChange(key, res)
 	if res.Status != Dropped {
-		c.cache.Set(url, res)
+		c.cache.Set(key, res)
 	}
 	return res
 }
 
+// PACStatus reports the health of the PAC script, nil if none is configured
+func (c *CachedURLChecker) PACStatus() *PACStatus {
+	return c.ccLimitedChecker.client.checker.PACStatus()
+}
+
 func (c *CachedURLChecker) shouldTakeCachedResult(res *URLCheckResult) bool {
+	if c.expired(res) {
+		return false
+	}
 	return res.Status == Ok ||
 		res.Status == Skipped ||
 		time.Now().Unix() <= res.FetchedAtEpochSeconds+c.retryFailedAfterSeconds
 }
+
+// expired is true for the results kept past their expiration to be revalidated
+func (c *CachedURLChecker) expired(res *URLCheckResult) bool {
+	return c.revalidate && time.Now().Unix() > res.FetchedAtEpochSeconds+c.expirationSeconds
+}
+
+func newContentHashCache(settings cacheSettings) *cache.Cache {
+	if !settings.detectContentChanges {
+		return nil
+	}
+	return cache.New(settings.contentHashRetention, settings.cacheCleanupInterval)
+}
+
+// withContentChange compares the content hash of a new result to the one of the previous check of the URL
+func (c *CachedURLChecker) withContentChange(key string, res *URLCheckResult) *URLCheckResult {
+	if c.contentHashes == nil || res.ContentHash == "" {
+		return res
+	}
+	previous, found := c.contentHashes.Get(key)
+	c.contentHashes.SetDefault(key, res.ContentHash)
+	if !found {
+		return res
+	}
+	res.PreviousContentHash = previous.(string)
+	res.ContentChanged = res.PreviousContentHash != res.ContentHash
+	return res
+}
@@ -82,6 +82,11 @@ func (r *CCLimitedURLChecker) CheckURL(ctx context.Context, url string) *URLChec
 	return r.checkURL(ctx, url)
 }
 
+// cacheKeyOf keys the results of the URL as the wrapped checker does
+func (r *CCLimitedURLChecker) cacheKeyOf(url string) string {
+	return r.client.cacheKeyOf(url)
+}
+
 func droppedResult(nowEpoch int64, err error) *URLCheckResult {
 	return &URLCheckResult{
 		Status:                Dropped,
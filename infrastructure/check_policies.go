@@ -0,0 +1,206 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/gobwas/glob"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// checkMethod is the sequence of requests sent to check a URL
+type checkMethod string
+
+const (
+	checkMethodHeadThenGet checkMethod = "head-then-get"
+	checkMethodHead        checkMethod = "head"
+	checkMethodGet         checkMethod = "get"
+)
+
+// CheckPolicyConfig is unmarshalled from the configuration file. A policy applies to the URLs matching
+// both the domain glob and the URL regex, if given. The first matching policy wins.
+type CheckPolicyConfig struct {
+	Name   string
+	Domain string
+	URL    string
+	// Method is one of head-then-get (default), head or get
+	Method         string
+	TimeoutSeconds uint
+	// OkStatusCodes are accepted as ok in addition to the 2xx status codes, e.g. 999 or 401
+	OkStatusCodes []int
+	// RetryStatusCodes replace the status codes falling back to a GET request, or to the next checker plugin
+	RetryStatusCodes []int
+	// BodyPatterns are the names of the body patterns to search for instead of all of them
+	BodyPatterns []string
+	// WebSocketSubprotocol is required to be accepted by the WebSocket endpoints
+	WebSocketSubprotocol string
+}
+
+type checkPolicy struct {
+	name             string
+	domain           glob.Glob
+	url              *regexp.Regexp
+	method           checkMethod
+	timeoutSeconds   uint
+	okStatusCodes    []int
+	retryStatusCodes []int
+	// bodyPatterns is nil if the global body pattern settings apply
+	bodyPatterns []bodyPattern
+
+	webSocketSubprotocol string
+}
+
+// defaultCheckPolicy applies to the URLs not matching any configured policy
+var defaultCheckPolicy = &checkPolicy{method: checkMethodHeadThenGet}
+
+func checkPolicyConfigsFromViper() []CheckPolicyConfig {
+	var configs []CheckPolicyConfig
+	if err := viper.UnmarshalKey("checkPolicies", &configs); err != nil {
+		panic(fmt.Errorf("could not parse the checkPolicies configuration: %v", err.Error()))
+	}
+	return configs
+}
+
+// checkPoliciesSearchBodyPatterns is true if the body patterns need to be loaded for any policy
+func checkPoliciesSearchBodyPatterns() bool {
+	for _, config := range checkPolicyConfigsFromViper() {
+		if len(config.BodyPatterns) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func loadCheckPoliciesFromViper(s *urlCheckerSettings) {
+	for _, config := range checkPolicyConfigsFromViper() {
+		policy, err := config.policy(s.BodyPatterns)
+		if err != nil {
+			panic(fmt.Errorf("could not load the check policy '%v': %v", config.Name, err.Error()))
+		}
+		s.CheckPolicies = append(s.CheckPolicies, policy)
+		log.Info().Msgf("Check policy '%v' defined for domains matching '%v' and URLs matching '%v'", policy.name, config.Domain, config.URL)
+	}
+}
+
+func (config CheckPolicyConfig) policy(bodyPatterns []bodyPattern) (*checkPolicy, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("a name is required")
+	}
+	if config.Domain == "" && config.URL == "" {
+		return nil, fmt.Errorf("either a domain glob or a URL regex is required")
+	}
+	policy := &checkPolicy{
+		name:                 config.Name,
+		method:               checkMethodHeadThenGet,
+		timeoutSeconds:       config.TimeoutSeconds,
+		okStatusCodes:        config.OkStatusCodes,
+		retryStatusCodes:     config.RetryStatusCodes,
+		webSocketSubprotocol: config.WebSocketSubprotocol,
+	}
+	var err error
+	if config.Domain != "" {
+		if policy.domain, err = glob.Compile(config.Domain); err != nil {
+			return nil, err
+		}
+	}
+	if config.URL != "" {
+		if policy.url, err = regexp.Compile(config.URL); err != nil {
+			return nil, err
+		}
+	}
+	switch method := checkMethod(config.Method); method {
+	case "":
+	case checkMethodHeadThenGet, checkMethodHead, checkMethodGet:
+		policy.method = method
+	default:
+		return nil, fmt.Errorf("unknown method '%v', expected one of %v, %v or %v", method, checkMethodHeadThenGet, checkMethodHead, checkMethodGet)
+	}
+	for _, name := range config.BodyPatterns {
+		i := slices.IndexFunc(bodyPatterns, func(p bodyPattern) bool { return p.name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown body pattern '%v'", name)
+		}
+		policy.bodyPatterns = append(policy.bodyPatterns, bodyPatterns[i])
+	}
+	return policy, nil
+}
+
+func (p *checkPolicy) matches(urlToCheck string) bool {
+	return (p.domain == nil || p.domain.Match(DomainOf(urlToCheck))) &&
+		(p.url == nil || p.url.MatchString(urlToCheck))
+}
+
+// checkPolicyFor returns the first policy matching the URL, or the default one
+func (s urlCheckerSettings) checkPolicyFor(urlToCheck string) *checkPolicy {
+	for _, policy := range s.CheckPolicies {
+		if policy.matches(urlToCheck) {
+			return policy
+		}
+	}
+	return defaultCheckPolicy
+}
+
+type checkPolicyKey struct{}
+
+func withCheckPolicy(ctx context.Context, policy *checkPolicy) context.Context {
+	return context.WithValue(ctx, checkPolicyKey{}, policy)
+}
+
+// checkPolicyOf returns the policy of the current check, or the default one
+func checkPolicyOf(ctx context.Context) *checkPolicy {
+	if policy, ok := ctx.Value(checkPolicyKey{}).(*checkPolicy); ok {
+		return policy
+	}
+	return defaultCheckPolicy
+}
+
+func (p *checkPolicy) timeoutOr(defaultTimeoutSeconds uint) uint {
+	if p.timeoutSeconds > 0 {
+		return p.timeoutSeconds
+	}
+	return defaultTimeoutSeconds
+}
+
+func (p *checkPolicy) shouldRetryBasedOnStatus(code int) bool {
+	if p.retryStatusCodes != nil {
+		return slices.Contains(p.retryStatusCodes, code)
+	}
+	return shouldRetryBasedOnStatus(code)
+}
+
+// shouldTryNextChecker is true if another checker plugin, e.g. one using another proxy, may come to a different result
+func (p *checkPolicy) shouldTryNextChecker(res *URLCheckResult) bool {
+	if res.Status == Ok {
+		return false
+	}
+	return p.shouldRetryBasedOnStatus(res.Code) || res.ErrorCategory == ErrorCategoryProxyAuthRequired
+}
+
+// bodyPatternsOf returns the body patterns to search for, and whether to search at all
+func (p *checkPolicy) bodyPatternsOf(s urlCheckerSettings) ([]bodyPattern, bool) {
+	if p.bodyPatterns != nil {
+		return p.bodyPatterns, true
+	}
+	return s.BodyPatterns, s.SearchForBodyPatterns
+}
+
+// acceptingOkStatus turns responses with a status code accepted by the policy into ok results
+func (p *checkPolicy) acceptingOkStatus(res *URLCheckResult) *URLCheckResult {
+	if res.Status != Broken || res.ErrorCategory != "" || res.brokenBecause != "" || !slices.Contains(p.okStatusCodes, res.Code) {
+		return res
+	}
+	log.Debug().Msgf("Accepting the %v status as ok according to the check policy '%v'", res.Code, p.name)
+	res.Status = Ok
+	res.Error = nil
+	res.retryAfter = 0
+	return res
+}
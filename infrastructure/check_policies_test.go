@@ -0,0 +1,165 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// policyTestServer answers HEAD requests to /no-head with 405, /status/<code> with the code,
+// and /slow after a delay, and records the methods per path
+func policyTestServer(t *testing.T) (*httptest.Server, func(path string) []string) {
+	var mu sync.Mutex
+	methods := map[string][]string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods[r.URL.Path] = append(methods[r.URL.Path], r.Method)
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/no-head" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case r.URL.Path == "/flaky-head" && r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasPrefix(r.URL.Path, "/status/"):
+			var code int
+			_, _ = fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/status/"), "%d", &code)
+			w.WriteHeader(code)
+		case r.URL.Path == "/slow":
+			time.Sleep(1500 * time.Millisecond)
+		case r.URL.Path == "/login":
+			_, _ = fmt.Fprint(w, "<html>Please sign in</html>")
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func(path string) []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return methods[path]
+	}
+}
+
+func TestCheckPoliciesAcceptStatusCodes(t *testing.T) {
+	ts, _ := policyTestServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("checkPolicies", []CheckPolicyConfig{
+		{Name: "linkedin", URL: "/status/999$", OkStatusCodes: []int{999}},
+		{Name: "sso", Domain: "127.0.0.?", URL: "/status/401$", OkStatusCodes: []int{401}},
+		{Name: "other domain", Domain: "*.example.com", OkStatusCodes: []int{404}},
+	})
+	c := NewURLCheckerClient()
+
+	tests := []struct {
+		path   string
+		status URLCheckStatus
+		policy string
+	}{
+		{"/status/999", Ok, "linkedin"},
+		{"/status/401", Ok, "sso"},
+		{"/status/404", Broken, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			res := c.CheckURL(context.Background(), ts.URL+tt.path)
+			assert.Equal(t, tt.status, res.Status, "%v", res.Error)
+			assert.Equal(t, tt.policy, res.Policy)
+		})
+	}
+}
+
+func TestCheckPolicyMethods(t *testing.T) {
+	ts, methodsOf := policyTestServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("checkPolicies", []CheckPolicyConfig{
+		{Name: "head only", URL: "/no-head$", Method: "head"},
+		{Name: "get only", URL: "/get$", Method: "get"},
+		{Name: "retry on 500", URL: "/flaky-head$", RetryStatusCodes: []int{500}},
+	})
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL+"/no-head")
+	assert.Equal(t, Broken, res.Status)
+	assert.Equal(t, []string{http.MethodHead}, methodsOf("/no-head"))
+
+	res = c.CheckURL(context.Background(), ts.URL+"/get")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, []string{http.MethodGet}, methodsOf("/get"))
+
+	res = c.CheckURL(context.Background(), ts.URL+"/flaky-head")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, []string{http.MethodHead, http.MethodGet}, methodsOf("/flaky-head"))
+
+	viper.Set("checkPolicies", nil)
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/flaky-head")
+	assert.Equal(t, Broken, res.Status, "500 does not fall back to a GET request by default")
+}
+
+func TestCheckPolicyTimeouts(t *testing.T) {
+	ts, _ := policyTestServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.timeoutSeconds", uint(1))
+	viper.Set("checkPolicies", []CheckPolicyConfig{
+		{Name: "slow", URL: "/slow$", TimeoutSeconds: 5},
+	})
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL+"/slow")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+
+	res = c.CheckURL(context.Background(), ts.URL+"/slow?not-matching")
+	assert.Equal(t, ErrorCategoryTimeoutRead, res.ErrorCategory)
+}
+
+func TestCheckPolicyBodyPatterns(t *testing.T) {
+	ts, _ := policyTestServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("bodyPatterns", []BodyPatternConfig{
+		{Name: "login", Regex: "sign in", Effect: "mark_requires_auth"},
+		{Name: "html", Regex: "<html>"},
+	})
+	viper.Set("checkPolicies", []CheckPolicyConfig{
+		{Name: "wiki", URL: "/login$", BodyPatterns: []string{"login"}},
+	})
+	t.Cleanup(func() { viper.Set("bodyPatterns", nil) })
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL+"/login")
+	assert.Equal(t, RequiresAuth, res.Status)
+	assert.Equal(t, []string{"login"}, res.BodyPatternsFound)
+
+	res = c.CheckURL(context.Background(), ts.URL+"/login?not-matching")
+	assert.Equal(t, Ok, res.Status, "body patterns are only searched for via the policy")
+	assert.Empty(t, res.BodyPatternsFound)
+}
+
+func TestInvalidCheckPolicies(t *testing.T) {
+	patterns := []bodyPattern{{name: "login"}}
+	_, err := CheckPolicyConfig{Name: "no matcher"}.policy(patterns)
+	assert.Error(t, err)
+	_, err = CheckPolicyConfig{Name: "method", Domain: "*", Method: "post"}.policy(patterns)
+	assert.Error(t, err)
+	_, err = CheckPolicyConfig{Name: "pattern", Domain: "*", BodyPatterns: []string{"unknown"}}.policy(patterns)
+	assert.Error(t, err)
+	_, err = CheckPolicyConfig{Domain: "*"}.policy(patterns)
+	assert.Error(t, err, "a name is required to trace the policy")
+
+	policy, err := CheckPolicyConfig{Name: "ok", URL: "^https://www\\.linkedin\\.com/", BodyPatterns: []string{"login"}}.policy(patterns)
+	require.NoError(t, err)
+	assert.Equal(t, checkMethodHeadThenGet, policy.method)
+	assert.True(t, policy.matches("https://www.linkedin.com/in/someone"))
+	assert.False(t, policy.matches("https://linkedin.com/"))
+}
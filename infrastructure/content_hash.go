@@ -0,0 +1,193 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"golang.org/x/net/html"
+)
+
+// contentHashMaxBodyBytes limits the part of a response body the content hash is computed of
+const contentHashMaxBodyBytes = 4 << 20
+
+// contentHashSettings configure the normalization of the content before hashing it
+type contentHashSettings struct {
+	// selector limits the hashed text to the matching elements of HTML documents, nil for the whole document
+	selector contentSelector
+	// ignorePatterns match volatile regions of the text, e.g. timestamps, removed before hashing
+	ignorePatterns []*regexp.Regexp
+}
+
+func loadContentHashFromViper(s *urlCheckerSettings) {
+	if !viper.GetBool("detectContentChanges") {
+		return
+	}
+	settings := &contentHashSettings{}
+	selector, err := parseContentSelector(viper.GetString("contentSelector"))
+	if err != nil {
+		panic(fmt.Errorf("could not parse the contentSelector: %v", err.Error()))
+	}
+	settings.selector = selector
+	for _, pattern := range viper.GetStringSlice("contentIgnorePatterns") {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			panic(fmt.Errorf("could not parse the content ignore pattern '%v': %v", pattern, err.Error()))
+		}
+		settings.ignorePatterns = append(settings.ignorePatterns, re)
+	}
+	s.ContentHash = settings
+	log.Info().Msgf("Will detect content changes, hashing '%v' and ignoring %v patterns",
+		viper.GetString("contentSelector"), len(settings.ignorePatterns))
+}
+
+// hashOf returns the hash of the normalized content of a response body
+func (s *contentHashSettings) hashOf(body []byte, isHTMLDocument bool) string {
+	text := string(body)
+	if isHTMLDocument {
+		text = s.textOf(body)
+	}
+	for _, re := range s.ignorePatterns {
+		text = re.ReplaceAllString(text, "")
+	}
+	// whitespace changes, e.g. a reformatted document, are not content changes
+	normalized := strings.Join(strings.Fields(text), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// textOf returns the text of the elements matching the selector, or of the whole document.
+// Scripts and styles are not part of the text.
+func (s *contentHashSettings) textOf(body []byte) string {
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return string(body)
+	}
+	var b strings.Builder
+	var walk func(n *html.Node, ancestors []*html.Node, selected bool)
+	walk = func(n *html.Node, ancestors []*html.Node, selected bool) {
+		switch n.Type {
+		case html.TextNode:
+			if selected {
+				b.WriteString(n.Data)
+				b.WriteString(" ")
+			}
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "script", "style", "noscript", "template":
+				return
+			}
+			selected = selected || s.selector.matches(n, ancestors)
+			ancestors = append(ancestors, n)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, ancestors, selected)
+		}
+	}
+	walk(doc, nil, s.selector == nil)
+	return b.String()
+}
+
+// contentSelector is a simple CSS selector: compound selectors of a tag name, an #id and .classes,
+// combined by descendant combinators, e.g. "main div.policy" or "#content"
+type contentSelector []compoundSelector
+
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+}
+
+var compoundSelectorPartRegex = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*|\*)?((?:[#.][a-zA-Z0-9_-]+)*)$`)
+var compoundSelectorQualifierRegex = regexp.MustCompile(`[#.][a-zA-Z0-9_-]+`)
+
+func parseContentSelector(selector string) (contentSelector, error) {
+	var res contentSelector
+	for _, part := range strings.Fields(selector) {
+		m := compoundSelectorPartRegex.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("unsupported selector '%v', expected e.g. 'main div.policy' or '#content'", part)
+		}
+		compound := compoundSelector{tag: strings.ToLower(strings.TrimPrefix(m[1], "*"))}
+		for _, qualifier := range compoundSelectorQualifierRegex.FindAllString(m[2], -1) {
+			if qualifier[0] == '#' {
+				compound.id = qualifier[1:]
+			} else {
+				compound.classes = append(compound.classes, qualifier[1:])
+			}
+		}
+		res = append(res, compound)
+	}
+	return res, nil
+}
+
+// matches is true if the node matches the last compound selector, and its ancestors the previous ones in order
+func (s contentSelector) matches(n *html.Node, ancestors []*html.Node) bool {
+	if len(s) == 0 || !s[len(s)-1].matches(n) {
+		return false
+	}
+	remaining := s[:len(s)-1]
+	for i := len(ancestors) - 1; i >= 0 && len(remaining) > 0; i-- {
+		if remaining[len(remaining)-1].matches(ancestors[i]) {
+			remaining = remaining[:len(remaining)-1]
+		}
+	}
+	return len(remaining) == 0
+}
+
+func (c compoundSelector) matches(n *html.Node) bool {
+	if c.tag != "" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" && attributeOf(n, "id") != c.id {
+		return false
+	}
+	classes := strings.Fields(attributeOf(n, "class"))
+	for _, class := range c.classes {
+		if !slices.Contains(classes, class) {
+			return false
+		}
+	}
+	return true
+}
+
+func attributeOf(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// withContentHash hashes the content of successful responses, up to contentHashMaxBodyBytes. The body
+// is returned in full to be searched for body patterns, starting with the part read into memory.
+func (c *URLCheckerClient) withContentHash(res *URLCheckResult, response *resty.Response, body io.Reader) (*URLCheckResult, io.Reader) {
+	if res.Code < 200 || res.Code >= 300 || response == nil {
+		return res, body
+	}
+	content, err := io.ReadAll(io.LimitReader(body, contentHashMaxBodyBytes))
+	// a partially read body would be reported as a change
+	rest := io.MultiReader(bytes.NewReader(content), body)
+	if err != nil {
+		log.Debug().Err(err).Msg("Could not read the content to hash")
+		return res, rest
+	}
+	res.ContentHash = c.settings.ContentHash.hashOf(content, isHTML(response.Header()))
+	return res, rest
+}
@@ -0,0 +1,129 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const samplePolicyPage = `<html>
+<head><title>Policy</title><script>var csrf = "%v";</script></head>
+<body>
+<nav>Generated at %v</nav>
+<main><div class="policy text">%v</div></main>
+</body>
+</html>`
+
+func TestContentHashNormalization(t *testing.T) {
+	settings := &contentHashSettings{
+		ignorePatterns: []*regexp.Regexp{regexp.MustCompile(`\d{4}-\d{2}-\d{2}T[\d:]+Z`)},
+	}
+	page := func(token, generatedAt, text string) []byte {
+		return []byte(fmt.Sprintf(samplePolicyPage, token, generatedAt, text))
+	}
+	hash := settings.hashOf(page("a1", "2024-01-01T10:00:00Z", "Terms apply."), true)
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, settings.hashOf(page("b2", "2024-01-02T11:30:00Z", "Terms apply."), true),
+		"scripts and volatile regions are ignored")
+	assert.Equal(t, hash, settings.hashOf(page("a1", "2024-01-01T10:00:00Z", "\n  Terms\tapply. "), true),
+		"whitespace is ignored")
+	assert.NotEqual(t, hash, settings.hashOf(page("a1", "2024-01-01T10:00:00Z", "Terms no longer apply."), true))
+
+	selector, err := parseContentSelector("main .policy")
+	require.NoError(t, err)
+	settings = &contentHashSettings{selector: selector}
+	assert.Equal(t,
+		settings.hashOf(page("a1", "2024-01-01T10:00:00Z", "Terms apply."), true),
+		settings.hashOf(page("b2", "2024-01-02T11:30:00Z", "Terms apply."), true),
+		"only the text of the selected elements is hashed")
+	assert.Equal(t, settings.hashOf([]byte("Terms apply."), false), settings.hashOf(page("a1", "", "Terms apply."), true))
+}
+
+func TestParsingContentSelectors(t *testing.T) {
+	selector, err := parseContentSelector("main div#content.policy.text *.note")
+	require.NoError(t, err)
+	assert.Equal(t, contentSelector{
+		{tag: "main"},
+		{tag: "div", id: "content", classes: []string{"policy", "text"}},
+		{classes: []string{"note"}},
+	}, selector)
+
+	selector, err = parseContentSelector("")
+	require.NoError(t, err)
+	assert.Nil(t, selector)
+
+	for _, unsupported := range []string{"main > div", "a[href]", "p:first-child", "div,p"} {
+		_, err = parseContentSelector(unsupported)
+		assert.Error(t, err, unsupported)
+	}
+}
+
+func TestContentChangesAreDetected(t *testing.T) {
+	var text atomic.Value
+	text.Store("Terms apply.")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = fmt.Fprintf(w, samplePolicyPage, time.Now().UnixNano(), time.Now().Format(time.RFC3339Nano), text.Load())
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("cacheExpirationInterval", "1ns")
+	viper.Set("detectContentChanges", true)
+	viper.Set("contentSelector", "div.policy")
+	c := NewCachedURLChecker()
+
+	first := c.CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, first.Status, "%v", first.Error)
+	assert.NotEmpty(t, first.ContentHash)
+	assert.Empty(t, first.PreviousContentHash, "the URL was not checked before")
+	assert.False(t, first.ContentChanged)
+
+	res := c.CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, first.ContentHash, res.ContentHash)
+	assert.Equal(t, first.ContentHash, res.PreviousContentHash)
+	assert.False(t, res.ContentChanged)
+
+	text.Store("Terms no longer apply.")
+	res = c.CheckURL(context.Background(), ts.URL)
+	assert.NotEqual(t, first.ContentHash, res.ContentHash)
+	assert.Equal(t, first.ContentHash, res.PreviousContentHash)
+	assert.True(t, res.ContentChanged)
+}
+
+func TestBodyPatternsBeyondTheHashedContentAreFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write(bytes.Repeat([]byte("padding "), contentHashMaxBodyBytes/8+1024))
+		_, _ = w.Write([]byte("deprecated"))
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("detectContentChanges", true)
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{{Name: "deprecated", Regex: "deprecated"}})
+	t.Cleanup(func() { viper.Set("bodyPatterns", nil) })
+
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.NotEmpty(t, res.ContentHash)
+	assert.Equal(t, []string{"deprecated"}, res.BodyPatternsFound)
+	assert.Greater(t, res.BodyBytesInspected, int64(contentHashMaxBodyBytes))
+}
@@ -0,0 +1,94 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	netUrl "net/url"
+
+	"github.com/gobwas/glob"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"golang.org/x/net/publicsuffix"
+)
+
+// cookieJars hold the cookies set during the checks: each check has its own jar, while the cookies
+// of the domains opted in to persistent cookies are kept in a jar shared by all checks
+type cookieJars struct {
+	persistentDomains []glob.Glob
+	persistent        http.CookieJar
+}
+
+func loadCookieJarsFromViper(s *urlCheckerSettings) {
+	s.CookieJars = &cookieJars{persistent: newCookieJar()}
+	for _, domain := range viper.GetStringSlice("persistentCookieDomains") {
+		g, err := glob.Compile(domain)
+		if err != nil {
+			panic(fmt.Errorf("could not parse the persistent cookie domain '%v': %v", domain, err.Error()))
+		}
+		s.CookieJars.persistentDomains = append(s.CookieJars.persistentDomains, g)
+	}
+	if len(s.CookieJars.persistentDomains) > 0 {
+		log.Info().Msgf("Will keep the cookies of %v persistent cookie domain globs across checks", len(s.CookieJars.persistentDomains))
+	}
+}
+
+func newCookieJar() http.CookieJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return jar
+}
+
+type cookieJarKey struct{}
+
+// withCookieJar gives a check its own cookie jar, used by its redirects and fallback requests only
+func withCookieJar(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cookieJarKey{}, newCookieJar())
+}
+
+// jarFor returns the persistent jar for the opted-in domains, or the jar of the check, nil if there is none
+func (j *cookieJars) jarFor(ctx context.Context, u *netUrl.URL) http.CookieJar {
+	if j != nil {
+		for _, g := range j.persistentDomains {
+			if g.Match(u.Hostname()) {
+				return j.persistent
+			}
+		}
+	}
+	jar, _ := ctx.Value(cookieJarKey{}).(http.CookieJar)
+	return jar
+}
+
+// cookieTransport sends and stores cookies via the jar of the check a request belongs to. Clients are shared
+// by the checks, thus their jar cannot be used.
+type cookieTransport struct {
+	next http.RoundTripper
+	jars *cookieJars
+}
+
+func (t *cookieTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jar := t.jars.jarFor(req.Context(), req.URL)
+	if jar == nil {
+		return t.next.RoundTrip(req)
+	}
+	if cookies := jar.Cookies(req.URL); len(cookies) > 0 {
+		req = req.Clone(req.Context())
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+	}
+	response, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if cookies := response.Cookies(); len(cookies) > 0 {
+		jar.SetCookies(req.URL, cookies)
+	}
+	return response, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// cookieTestServer redirects requests to /handshake without a session cookie to itself, setting the cookie.
+// HEAD requests to /head-sets-cookie set the cookie, while GET requests require it.
+// /echo records the session cookie received.
+func cookieTestServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var echoed []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, err := r.Cookie("session")
+		hasSession := err == nil && session.Value == "s1"
+		setSession := func() { http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"}) }
+		switch r.URL.Path {
+		case "/handshake":
+			if !hasSession {
+				setSession()
+				http.Redirect(w, r, "/handshake", http.StatusFound)
+			}
+		case "/head-sets-cookie":
+			if r.Method == http.MethodHead {
+				setSession()
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			} else if !hasSession {
+				w.WriteHeader(http.StatusForbidden)
+			}
+		case "/echo":
+			mu.Lock()
+			echoed = append(echoed, r.Header.Get("Cookie"))
+			mu.Unlock()
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return echoed
+	}
+}
+
+func TestCookiesAreKeptWithinACheck(t *testing.T) {
+	ts, _ := cookieTestServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.maxRedirectsCount", uint(3))
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL+"/handshake")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Len(t, res.Redirects, 1)
+
+	res = c.CheckURL(context.Background(), ts.URL+"/head-sets-cookie")
+	assert.Equal(t, Ok, res.Status, "the cookie set on HEAD is sent on the GET fallback: %v", res.Error)
+}
+
+func TestCookiesAreNotSharedBetweenChecks(t *testing.T) {
+	ts, echoed := cookieTestServer(t)
+	setUpViperTestConfiguration()
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL+"/handshake")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	res = c.CheckURL(context.Background(), ts.URL+"/echo")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, []string{""}, echoed())
+
+	viper.Set("persistentCookieDomains", []string{"127.0.0.*"})
+	c = NewURLCheckerClient()
+	res = c.CheckURL(context.Background(), ts.URL+"/handshake")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Len(t, res.Redirects, 1)
+	res = c.CheckURL(context.Background(), ts.URL+"/handshake")
+	assert.Empty(t, res.Redirects, "the persistent cookie is sent right away")
+	res = c.CheckURL(context.Background(), ts.URL+"/echo")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, []string{"", "session=s1"}, echoed())
+}
@@ -0,0 +1,193 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gobwas/glob"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const secretFromEnvPrefix = "env:"
+const secretFromFilePrefix = "file:"
+
+// DomainCredentialsConfig is unmarshalled from the configuration file. All values except
+// the name and the domain may reference a secret via "env:<VARIABLE>" or "file:<path>".
+type DomainCredentialsConfig struct {
+	// Name identifies the rule in the logs, defaults to the domain glob
+	Name        string
+	Domain      string
+	Headers     map[string]string
+	Username    string
+	Password    string
+	BearerToken string
+	// Cookies are given as "name=value"
+	Cookies []string
+}
+
+// credentialRule holds the resolved secrets. Its fields must never be logged.
+type credentialRule struct {
+	name        string
+	domain      glob.Glob
+	headers     map[string]string
+	username    string
+	password    string
+	bearerToken string
+	cookies     []*http.Cookie
+}
+
+func loadCredentialsFromViper(s *urlCheckerSettings) {
+	var configs []DomainCredentialsConfig
+	if err := viper.UnmarshalKey("credentials", &configs); err != nil {
+		panic(fmt.Errorf("could not parse the credentials configuration: %v", err.Error()))
+	}
+	for _, config := range configs {
+		rule, err := config.rule()
+		if err != nil {
+			panic(fmt.Errorf("could not load the credentials '%v': %v", config.nameOrDomain(), err.Error()))
+		}
+		s.Credentials = append(s.Credentials, rule)
+		log.Info().Msgf("Credentials '%v' defined for domains matching '%v'", rule.name, config.Domain)
+	}
+}
+
+func (config DomainCredentialsConfig) nameOrDomain() string {
+	if config.Name != "" {
+		return config.Name
+	}
+	return config.Domain
+}
+
+func (config DomainCredentialsConfig) rule() (credentialRule, error) {
+	domain, err := glob.Compile(config.Domain)
+	if err != nil {
+		return credentialRule{}, err
+	}
+	rule := credentialRule{
+		name:    config.nameOrDomain(),
+		domain:  domain,
+		headers: map[string]string{},
+	}
+	for name, value := range config.Headers {
+		if rule.headers[name], err = secretOf(value); err != nil {
+			return credentialRule{}, fmt.Errorf("header '%v': %w", name, err)
+		}
+	}
+	if rule.username, err = secretOf(config.Username); err != nil {
+		return credentialRule{}, fmt.Errorf("username: %w", err)
+	}
+	if rule.password, err = secretOf(config.Password); err != nil {
+		return credentialRule{}, fmt.Errorf("password: %w", err)
+	}
+	if rule.bearerToken, err = secretOf(config.BearerToken); err != nil {
+		return credentialRule{}, fmt.Errorf("bearer token: %w", err)
+	}
+	if rule.bearerToken != "" && (rule.username != "" || rule.password != "") {
+		return credentialRule{}, fmt.Errorf("either basic auth or a bearer token can be used")
+	}
+	for _, cookie := range config.Cookies {
+		name, value, found := strings.Cut(cookie, "=")
+		if !found || strings.TrimSpace(name) == "" {
+			return credentialRule{}, fmt.Errorf("cookies have to be given as name=value")
+		}
+		if value, err = secretOf(value); err != nil {
+			return credentialRule{}, fmt.Errorf("cookie '%v': %w", name, err)
+		}
+		rule.cookies = append(rule.cookies, &http.Cookie{Name: strings.TrimSpace(name), Value: value})
+	}
+	return rule, nil
+}
+
+// secretOf resolves a value referencing an environment variable or a file, or returns it as is.
+// The errors do not contain the secret.
+func secretOf(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretFromEnvPrefix):
+		variable := strings.TrimPrefix(value, secretFromEnvPrefix)
+		secret, ok := os.LookupEnv(variable)
+		if !ok {
+			return "", fmt.Errorf("environment variable '%v' is not set", variable)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, secretFromFilePrefix):
+		path := strings.TrimPrefix(value, secretFromFilePrefix)
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("could not read the secret file '%v'", path)
+		}
+		return strings.TrimRight(string(secret), "\r\n"), nil
+	default:
+		return value, nil
+	}
+}
+
+// credentialsFor picks the first rule matching the domain of the URL
+func (s urlCheckerSettings) credentialsFor(urlToCheck string) *credentialRule {
+	if len(s.Credentials) == 0 {
+		return nil
+	}
+	domain := DomainOf(urlToCheck)
+	for i := range s.Credentials {
+		if s.Credentials[i].domain.Match(domain) {
+			return &s.Credentials[i]
+		}
+	}
+	return nil
+}
+
+// cacheKeyOf separates the cached results of credentialed checks from anonymous ones
+func (s urlCheckerSettings) cacheKeyOf(urlToCheck string) string {
+	if rule := s.credentialsFor(urlToCheck); rule != nil {
+		return urlToCheck + " (credentials: " + rule.name + ")"
+	}
+	return urlToCheck
+}
+
+// cacheKeyOf keys the results of the URL by the credentials the client checks it with
+func (c *URLCheckerClient) cacheKeyOf(urlToCheck string) string {
+	return c.settings.cacheKeyOf(urlToCheck)
+}
+
+func (rule *credentialRule) applyTo(req *resty.Request) {
+	for name, value := range rule.headers {
+		req.SetHeader(name, value)
+	}
+	// setting the header directly, as resty would log a warning on each plain HTTP request
+	if rule.username != "" || rule.password != "" {
+		req.SetHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(rule.username+":"+rule.password)))
+	}
+	if rule.bearerToken != "" {
+		req.SetHeader("Authorization", "Bearer "+rule.bearerToken)
+	}
+	req.SetCookies(rule.cookies)
+}
+
+// credentialsRedirectPolicy removes the injected headers on redirects leaving the domains of the credentials.
+// The Authorization and Cookie headers are removed by the HTTP client on redirects to other domains already.
+func credentialsRedirectPolicy(rules []credentialRule) resty.RedirectPolicy {
+	return resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
+		if len(rules) == 0 || len(via) == 0 {
+			return nil
+		}
+		s := urlCheckerSettings{Credentials: rules}
+		original := s.credentialsFor(via[0].URL.String())
+		if original == nil || original.domain.Match(req.URL.Hostname()) {
+			return nil
+		}
+		for name := range original.headers {
+			req.Header.Del(name)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,139 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func protectedServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		cookie, _ := r.Cookie("Session")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/basic") && user == "checker" && password == "s3cret-from-file":
+		case strings.HasPrefix(r.URL.Path, "/bearer") && r.Header.Get("Authorization") == "Bearer t0ken":
+		case strings.HasPrefix(r.URL.Path, "/header") && r.Header.Get("Private-Token") == "s3cret-from-env":
+		case strings.HasPrefix(r.URL.Path, "/cookie") && cookie != nil && cookie.Value == "abc":
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestCredentialsAreAttachedPerDomain(t *testing.T) {
+	ts := protectedServer(t)
+	secretFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(secretFile, []byte("s3cret-from-file\n"), 0o600))
+	t.Setenv("LCS_TEST_TOKEN", "s3cret-from-env")
+
+	setUpViperTestConfiguration()
+	viper.Set("credentials", []DomainCredentialsConfig{
+		{Name: "wiki", Domain: "127.0.0.?", Username: "checker", Password: "file:" + secretFile,
+			Headers: map[string]string{"Private-Token": "env:LCS_TEST_TOKEN"}, Cookies: []string{"Session=abc"}},
+	})
+	c := NewURLCheckerClient()
+
+	for _, path := range []string{"/basic", "/header", "/cookie"} {
+		res := c.CheckURL(context.Background(), ts.URL+path)
+		assert.Equal(t, Ok, res.Status, path)
+	}
+
+	viper.Set("credentials", []DomainCredentialsConfig{
+		{Name: "artifacts", Domain: "127.0.0.?", BearerToken: "t0ken"},
+	})
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/bearer")
+	assert.Equal(t, Ok, res.Status)
+
+	viper.Set("credentials", []DomainCredentialsConfig{
+		{Domain: "*.example.com", BearerToken: "t0ken"},
+	})
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/bearer")
+	assert.Equal(t, http.StatusUnauthorized, res.Code, "credentials of other domains must not be attached")
+}
+
+func TestSecretsAreNotEchoedInTheTrace(t *testing.T) {
+	ts := protectedServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("credentials", []DomainCredentialsConfig{
+		{Domain: "127.0.0.?", Username: "checker", Password: "wrong-s3cret", Cookies: []string{"Session=wrong-c00kie"}},
+	})
+
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/basic")
+	assert.Equal(t, Broken, res.Status)
+	for _, trace := range res.CheckerTrace {
+		assert.NotContains(t, trace.Error, "wrong-")
+	}
+	assert.NotContains(t, res.Error.Error(), "wrong-")
+}
+
+func TestInjectedHeadersAreRemovedOnRedirectsToOtherDomains(t *testing.T) {
+	var leaked []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = append(leaked, r.Header.Get("Private-Token"))
+	}))
+	defer target.Close()
+	// same server, other domain
+	otherDomain := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	source := httptest.NewServer(http.RedirectHandler(otherDomain+"/landing", http.StatusFound))
+	defer source.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("credentials", []DomainCredentialsConfig{
+		{Domain: "127.0.0.1", Headers: map[string]string{"Private-Token": "s3cret"}},
+	})
+
+	res := NewURLCheckerClient().CheckURL(context.Background(), source.URL)
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	require.NotEmpty(t, leaked)
+	assert.Equal(t, []string{""}, leaked[:1])
+}
+
+func TestSecretReferences(t *testing.T) {
+	t.Setenv("LCS_TEST_SECRET", "from-env")
+	secret, err := secretOf("env:LCS_TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", secret)
+
+	_, err = secretOf("env:LCS_TEST_UNDEFINED_SECRET")
+	assert.Error(t, err)
+
+	_, err = secretOf("file:/non/existent/secret")
+	assert.Error(t, err)
+
+	_, err = DomainCredentialsConfig{Domain: "*", Username: "u", BearerToken: "t"}.rule()
+	assert.Error(t, err, "basic auth and a bearer token are mutually exclusive")
+
+	secret, err = secretOf("plain")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", secret)
+}
+
+func TestCredentialedResultsAreCachedSeparately(t *testing.T) {
+	setUpViperTestConfiguration()
+	viper.Set("credentials", []DomainCredentialsConfig{
+		{Name: "wiki", Domain: "wiki.example.com", BearerToken: "t0ken"},
+	})
+	settings := getURLCheckerSettings()
+
+	assert.Equal(t, "https://example.com/", settings.cacheKeyOf("https://example.com/"))
+	key := settings.cacheKeyOf("https://wiki.example.com/")
+	assert.NotEqual(t, "https://wiki.example.com/", key)
+	assert.NotContains(t, key, "t0ken")
+}
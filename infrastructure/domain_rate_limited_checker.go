@@ -38,25 +38,14 @@ func NewDomainRateLimitedChecker(ratePerSecond rate.Limit) *DomainRateLimitedChe
 
 // CheckURL checks the desired URL applying rate limits per domain
 func (c *DomainRateLimitedChecker) CheckURL(ctx context.Context, url string) *URLCheckResult {
+	limit := c.limitFor(url)
 	// if there's no limiting, just check
-	if c.ratePerSecond == 0 {
+	if limit == 0 {
 		return c.checker.CheckURL(ctx, url)
 	}
 
 	// limit per domain
-	key := DomainOf(url)
-	var limiter *rate.Limiter
-	if limiterInstance, ok := c.domains.Load(key); !ok {
-		limiter = rate.NewLimiter(c.ratePerSecond /*per second*/, 1 /*burst*/)
-	} else {
-		l, typeOK := limiterInstance.(*rate.Limiter)
-		if !typeOK {
-			limiter = rate.NewLimiter(c.ratePerSecond, 1)
-			c.domains.Store(key, limiter)
-		} else {
-			limiter = l
-		}
-	}
+	limiter := c.limiterOf(DomainOf(url), limit)
 	if err := limiter.Wait(ctx); err != nil {
 		nowEpoch := time.Now().Unix()
 
@@ -71,3 +60,29 @@ func (c *DomainRateLimitedChecker) CheckURL(ctx context.Context, url string) *UR
 	}
 	return c.checker.CheckURL(ctx, url)
 }
+
+// cacheKeyOf keys the results of the URL as the wrapped checker does
+func (c *DomainRateLimitedChecker) cacheKeyOf(url string) string {
+	return c.checker.cacheKeyOf(url)
+}
+
+// limitFor applies the Crawl-delay of the robots.txt of the URL's host, if it is stricter than the configured rate
+func (c *DomainRateLimitedChecker) limitFor(url string) rate.Limit {
+	limit := c.ratePerSecond
+	if delay := c.checker.crawlDelayOf(url); delay > 0 {
+		if crawlLimit := rate.Every(delay); limit == 0 || crawlLimit < limit {
+			limit = crawlLimit
+		}
+	}
+	return limit
+}
+
+// limiterOf returns the limiter shared by the checks of the domain, updated to the current limit
+func (c *DomainRateLimitedChecker) limiterOf(domain string, limit rate.Limit) *rate.Limiter {
+	l, _ := c.domains.LoadOrStore(domain, rate.NewLimiter(limit /*per second*/, 1 /*burst*/))
+	limiter := l.(*rate.Limiter)
+	if limiter.Limit() != limit {
+		limiter.SetLimit(limit)
+	}
+	return limiter
+}
@@ -0,0 +1,38 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecksAreRateLimitedPerDomain(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	setUpViperTestConfiguration()
+	c := NewDomainRateLimitedChecker(5)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		res := c.CheckURL(context.Background(), ts.URL)
+		assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+
+	c = NewDomainRateLimitedChecker(0)
+	start = time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, Ok, c.CheckURL(context.Background(), ts.URL).Status)
+	}
+	assert.Less(t, time.Since(start), 350*time.Millisecond, "no limit")
+}
@@ -0,0 +1,175 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+)
+
+// ErrorCategory classifies the reason a request failed without a response
+type ErrorCategory string
+
+// ErrorCategory values
+const (
+	ErrorCategoryDNSNotFound       ErrorCategory = "dns_not_found"
+	ErrorCategoryConnectionRefused ErrorCategory = "connection_refused"
+	ErrorCategoryConnectionReset   ErrorCategory = "connection_reset"
+	ErrorCategoryTLSHandshake      ErrorCategory = "tls_handshake"
+	ErrorCategoryCertInvalid       ErrorCategory = "cert_invalid"
+	ErrorCategoryTimeoutConnect    ErrorCategory = "timeout_connect"
+	ErrorCategoryTimeoutRead       ErrorCategory = "timeout_read"
+	ErrorCategoryProxyError        ErrorCategory = "proxy_error"
+	ErrorCategoryProxyAuthRequired ErrorCategory = "proxy_auth_required"
+	ErrorCategoryTooManyRedirects  ErrorCategory = "too_many_redirects"
+	// ErrorCategoryUnknown is used for failures not fitting any other category
+	ErrorCategoryUnknown ErrorCategory = "unknown"
+)
+
+// isGatewayFailure is true for the categories reported with the 502 status code, as the target might only be overloaded
+func (c ErrorCategory) isGatewayFailure() bool {
+	return c == ErrorCategoryTimeoutConnect || c == ErrorCategoryTimeoutRead || c == ErrorCategoryProxyError
+}
+
+type tooManyRedirectsError struct {
+	max int
+}
+
+func (e *tooManyRedirectsError) Error() string {
+	return fmt.Sprintf("stopped after %d redirects", e.max)
+}
+
+// proxyConnectError is returned if the proxy refused to tunnel a connection
+type proxyConnectError struct {
+	status string
+	code   int
+}
+
+func (e *proxyConnectError) Error() string {
+	return "proxy responded with " + e.status
+}
+
+func onProxyConnectResponse(_ context.Context, _ *url.URL, _ *http.Request, response *http.Response) error {
+	if response.StatusCode != http.StatusOK {
+		return &proxyConnectError{status: response.Status, code: response.StatusCode}
+	}
+	return nil
+}
+
+type connectionTrackerKey struct{}
+
+// connectionTracker tells whether the current request hop got a connection, i.e. timeouts happened while reading
+type connectionTracker struct {
+	connected atomic.Bool
+}
+
+func withConnectionTracker(ctx context.Context) context.Context {
+	tracker := &connectionTracker{}
+	ctx = context.WithValue(ctx, connectionTrackerKey{}, tracker)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(string) { tracker.connected.Store(false) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			tracker.connected.Store(true)
+			GlobalStats().OnConnection(info.Reused)
+		},
+	})
+}
+
+func connectedIn(ctx context.Context) bool {
+	tracker, _ := ctx.Value(connectionTrackerKey{}).(*connectionTracker)
+	return tracker != nil && tracker.connected.Load()
+}
+
+// errorCategoryOf classifies a request failure. Timeouts are attributed to reading if a connection was established.
+func errorCategoryOf(err error, connected bool) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	if c := errorCategoryOfCause(err); c != "" {
+		return c
+	}
+	if isTimeout(err) {
+		if connected {
+			return ErrorCategoryTimeoutRead
+		}
+		return ErrorCategoryTimeoutConnect
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return ErrorCategoryConnectionReset
+	}
+	return ErrorCategoryUnknown
+}
+
+func errorCategoryOfCause(err error) ErrorCategory {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var redirectsErr *tooManyRedirectsError
+	var proxyErr *proxyConnectError
+	switch {
+	case errors.As(err, &redirectsErr):
+		return ErrorCategoryTooManyRedirects
+	case errors.As(err, &proxyErr) && proxyErr.code == http.StatusProxyAuthRequired:
+		return ErrorCategoryProxyAuthRequired
+	case errors.As(err, &proxyErr):
+		return ErrorCategoryProxyError
+	case errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || opErr.Op == "socks connect"):
+		return ErrorCategoryProxyError
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsTimeout {
+			return ErrorCategoryTimeoutConnect
+		}
+		return ErrorCategoryDNSNotFound
+	case isCertificateError(err):
+		return ErrorCategoryCertInvalid
+	case isTLSHandshakeError(err):
+		return ErrorCategoryTLSHandshake
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorCategoryConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, syscall.ECONNABORTED):
+		return ErrorCategoryConnectionReset
+	}
+	return ""
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isCertificateError(err error) bool {
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
+
+func isTLSHandshakeError(err error) bool {
+	var recordHeaderErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var opErr *net.OpError
+	return errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &alertErr) ||
+		// alerts sent by the peer
+		(errors.As(err, &opErr) && (opErr.Op == "remote error" || opErr.Op == "local error"))
+}
@@ -0,0 +1,109 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCategoryOf(t *testing.T) {
+	urlError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: err}
+	}
+	tests := []struct {
+		name      string
+		err       error
+		connected bool
+		expected  ErrorCategory
+	}{
+		{"none", nil, false, ""},
+		{"dns", urlError(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", IsNotFound: true}}), false, ErrorCategoryDNSNotFound},
+		{"dns timeout", urlError(&net.OpError{Op: "dial", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}), false, ErrorCategoryTimeoutConnect},
+		{"refused", urlError(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), false, ErrorCategoryConnectionRefused},
+		{"reset", urlError(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true, ErrorCategoryConnectionReset},
+		{"eof", urlError(io.EOF), true, ErrorCategoryConnectionReset},
+		{"other", urlError(errors.New("http: server gave HTTP response to HTTPS client")), true, ErrorCategoryUnknown},
+		{"certificate", urlError(x509.UnknownAuthorityError{}), true, ErrorCategoryCertInvalid},
+		{"connect timeout", urlError(context.DeadlineExceeded), false, ErrorCategoryTimeoutConnect},
+		{"read timeout", urlError(context.DeadlineExceeded), true, ErrorCategoryTimeoutRead},
+		{"proxy", urlError(&net.OpError{Op: "proxyconnect", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), false, ErrorCategoryProxyError},
+		{"proxy status", urlError(&proxyConnectError{status: "502 Bad Gateway", code: http.StatusBadGateway}), false, ErrorCategoryProxyError},
+		{"proxy auth", urlError(&proxyConnectError{status: "407 Proxy Authentication Required", code: http.StatusProxyAuthRequired}), false, ErrorCategoryProxyAuthRequired},
+		{"redirects", urlError(&tooManyRedirectsError{max: 3}), true, ErrorCategoryTooManyRedirects},
+		{"tls alert", urlError(&net.OpError{Op: "remote error", Err: errors.New("tls: handshake failure")}), true, ErrorCategoryTLSHandshake},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, errorCategoryOf(tt.err, tt.connected))
+		})
+	}
+}
+
+func TestCategorizingFailedChecks(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(2 * time.Second)
+		}
+		if r.URL.Path == "/loop" {
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		}
+	}))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer secure.Close()
+	outdated := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	outdated.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS10}
+	outdated.StartTLS()
+	defer outdated.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedPort := "http://" + l.Addr().String()
+	require.NoError(t, l.Close())
+
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.timeoutSeconds", uint(1))
+	viper.Set("HTTPClient.maxRedirectsCount", uint(3))
+	c := NewURLCheckerClient()
+
+	tests := []struct {
+		url      string
+		expected ErrorCategory
+		code     int
+	}{
+		{closedPort, ErrorCategoryConnectionRefused, CustomHTTPErrorCode},
+		{plain.URL + "/slow", ErrorCategoryTimeoutRead, http.StatusBadGateway},
+		{plain.URL + "/loop", ErrorCategoryTooManyRedirects, CustomHTTPErrorCode},
+		{secure.URL, ErrorCategoryCertInvalid, CustomHTTPErrorCode},
+		{outdated.URL, ErrorCategoryTLSHandshake, CustomHTTPErrorCode},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.expected), func(t *testing.T) {
+			ResetGlobalStats()
+			res := c.CheckURL(context.Background(), tt.url)
+			assert.Equal(t, Broken, res.Status)
+			assert.Equal(t, tt.expected, res.ErrorCategory, "%v", res.Error)
+			assert.Equal(t, tt.code, res.Code)
+			assert.Equal(t, map[string]int64{string(tt.expected): 1}, GlobalStats().GetDomainStats().DomainStats["127.0.0.1"].BrokenBecause)
+		})
+	}
+}
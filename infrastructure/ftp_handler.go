@@ -0,0 +1,196 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	netUrl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultFTPPort = "21"
+
+// ftpMaxListingBytes limits the part of a directory listing read, only its presence matters
+const ftpMaxListingBytes = 64 << 10
+
+// ftpUnsafeChars would terminate an FTP command early, the rest of the argument being sent as further commands
+const ftpUnsafeChars = "\r\n\x00"
+
+var ftpPassivePortRegex = regexp.MustCompile(`\(\|\|\|(\d+)\|\)`)
+var ftpPassiveAddressRegex = regexp.MustCompile(`(\d+),(\d+),(\d+),(\d+),(\d+),(\d+)`)
+
+type ftpHandler struct {
+	c       *URLCheckerClient
+	timeout time.Duration
+}
+
+// check logs in (anonymously, unless credentials are part of the URL) and checks the path
+// via SIZE for files, falling back to LIST for directories
+func (h *ftpHandler) check(ctx context.Context, u *netUrl.URL) error {
+	if err := validateFTPURL(u); err != nil {
+		return err
+	}
+	port := u.Port()
+	if port == "" {
+		port = defaultFTPPort
+	}
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	conn, err := h.c.resolver.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	if h.timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(h.timeout))
+	}
+
+	ftp := textproto.NewConn(conn)
+	if _, _, err := ftp.ReadResponse(220); err != nil {
+		return fmt.Errorf("unexpected FTP greeting: %w", err)
+	}
+	if err := ftpLogin(ftp, u); err != nil {
+		return err
+	}
+
+	path := u.Path
+	if path == "" || path == "/" {
+		return nil
+	}
+	if _, err := ftpCommand(ftp, 213, "SIZE %s", path); err == nil {
+		return nil
+	}
+	if err := h.list(ctx, ftp, u.Hostname(), path); err != nil {
+		return fmt.Errorf("FTP path '%v' not found: %w", path, err)
+	}
+	return nil
+}
+
+// validateFTPURL rejects control characters in the parts of the URL sent as command arguments
+func validateFTPURL(u *netUrl.URL) error {
+	var user, password string
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	for _, part := range []string{u.Path, user, password} {
+		if strings.ContainsAny(part, ftpUnsafeChars) {
+			return fmt.Errorf("invalid FTP URL: control characters in the path or credentials")
+		}
+	}
+	return nil
+}
+
+// list requests the listing of the path via a passive data connection. Some servers answer LIST
+// of a non-existent path with an empty listing instead of an error, thus empty listings are not found.
+func (h *ftpHandler) list(ctx context.Context, ftp *textproto.Conn, host, path string) error {
+	port, err := ftpPassivePort(ftp)
+	if err != nil {
+		return err
+	}
+	// the address of the passive reply is ignored, as it is often unreachable behind NAT
+	data, err := h.c.resolver.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return fmt.Errorf("could not open the FTP data connection: %w", err)
+	}
+	defer func() { _ = data.Close() }()
+	if h.timeout > 0 {
+		_ = data.SetDeadline(time.Now().Add(h.timeout))
+	}
+
+	if _, _, err := ftpCommandWithMessage(ftp, 1, "LIST %s", path); err != nil {
+		return err
+	}
+	listing, err := io.ReadAll(io.LimitReader(data, ftpMaxListingBytes))
+	_ = data.Close()
+	if err != nil {
+		return err
+	}
+	if _, _, err := ftp.ReadResponse(2); err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(listing)) == 0 {
+		return fmt.Errorf("empty listing")
+	}
+	return nil
+}
+
+// ftpPassivePort enters the extended passive mode, falling back to the passive mode, and returns the data port
+func ftpPassivePort(ftp *textproto.Conn) (string, error) {
+	if _, message, err := ftpCommandWithMessage(ftp, 229, "EPSV"); err == nil {
+		if m := ftpPassivePortRegex.FindStringSubmatch(message); m != nil {
+			return m[1], nil
+		}
+	}
+	_, message, err := ftpCommandWithMessage(ftp, 227, "PASV")
+	if err != nil {
+		return "", err
+	}
+	m := ftpPassiveAddressRegex.FindStringSubmatch(message)
+	if m == nil {
+		return "", fmt.Errorf("unexpected FTP passive mode reply: '%v'", message)
+	}
+	high, _ := strconv.Atoi(m[5])
+	low, _ := strconv.Atoi(m[6])
+	return strconv.Itoa(high<<8 + low), nil
+}
+
+func ftpLogin(ftp *textproto.Conn, u *netUrl.URL) error {
+	user, password := "anonymous", "anonymous@"
+	if u.User != nil {
+		user = u.User.Username()
+		password, _ = u.User.Password()
+	}
+	code, err := ftpCommand(ftp, 0, "USER %s", user)
+	if err != nil {
+		return fmt.Errorf("FTP login failed: %w", err)
+	}
+	switch code {
+	case 230:
+		// logged in without a password
+	case 331, 332:
+		if _, err := ftpCommand(ftp, 230, "PASS %s", password); err != nil {
+			return fmt.Errorf("FTP login failed: %w", err)
+		}
+	default:
+		return fmt.Errorf("FTP login failed with code %v", code)
+	}
+	// SIZE is only reliable in binary mode
+	_, _ = ftpCommand(ftp, 200, "TYPE I")
+	return nil
+}
+
+// ftpCommand sends a command and reads its response. An expected code of 0 accepts any response code
+func ftpCommand(ftp *textproto.Conn, expectCode int, format string, args ...any) (int, error) {
+	code, _, err := ftpCommandWithMessage(ftp, expectCode, format, args...)
+	return code, err
+}
+
+func ftpCommandWithMessage(ftp *textproto.Conn, expectCode int, format string, args ...any) (int, string, error) {
+	id, err := ftp.Cmd(format, args...)
+	if err != nil {
+		return 0, "", err
+	}
+	ftp.StartResponse(id)
+	defer ftp.EndResponse(id)
+	code, message, err := ftp.ReadResponse(expectCode)
+	if _, ok := err.(*textproto.Error); ok && expectCode == 0 {
+		return code, message, nil
+	}
+	return code, message, err
+}
@@ -16,6 +16,7 @@ package impersonate
 
 import (
 	"crypto/tls"
+	"fmt"
 	"net/http"
 )
 
@@ -142,3 +143,98 @@ func ProfileByName(name string) Profile {
 		return ChromeProfile()
 	}
 }
+
+// LookupProfile returns the built-in profile matching the given name, and whether it was found.
+func LookupProfile(name string) (Profile, bool) {
+	switch name {
+	case profileNameChrome:
+		return ChromeProfile(), true
+	case profileNameFirefox:
+		return FirefoxProfile(), true
+	case profileNameSafari:
+		return SafariProfile(), true
+	default:
+		return Profile{}, false
+	}
+}
+
+// TLSConfig returns a fresh TLS client configuration carrying the profile's cipher suite
+// and curve preferences.
+func (p Profile) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CipherSuites:     p.CipherSuites,
+		CurvePreferences: p.CurvePreferences,
+	}
+}
+
+// ProfileConfig is unmarshalled from the configuration file to define custom profiles.
+// Unset fields are inherited from the Base profile (Chrome if unknown or empty).
+type ProfileConfig struct {
+	Name         string
+	Base         string
+	CipherSuites []string
+	Curves       []string
+	Headers      map[string]string
+}
+
+var curvesByName = map[string]tls.CurveID{
+	"X25519":         tls.X25519,
+	"P256":           tls.CurveP256,
+	"P384":           tls.CurveP384,
+	"P521":           tls.CurveP521,
+	"X25519MLKEM768": tls.X25519MLKEM768,
+}
+
+// Profile builds a profile from the configuration, rejecting unknown cipher suite and curve names
+func (c ProfileConfig) Profile() (Profile, error) {
+	if c.Name == "" {
+		return Profile{}, fmt.Errorf("impersonation profile without a name")
+	}
+	p := ProfileByName(c.Base)
+	p.Name = c.Name
+
+	if len(c.CipherSuites) > 0 {
+		suites, err := cipherSuitesByName(c.CipherSuites)
+		if err != nil {
+			return Profile{}, fmt.Errorf("profile %v: %w", c.Name, err)
+		}
+		p.CipherSuites = suites
+	}
+
+	if len(c.Curves) > 0 {
+		curves := make([]tls.CurveID, 0, len(c.Curves))
+		for _, name := range c.Curves {
+			curve, ok := curvesByName[name]
+			if !ok {
+				return Profile{}, fmt.Errorf("profile %v: unknown curve %v", c.Name, name)
+			}
+			curves = append(curves, curve)
+		}
+		p.CurvePreferences = curves
+	}
+
+	for k, v := range c.Headers {
+		p.DefaultHeaders.Set(k, v)
+	}
+	return p, nil
+}
+
+func cipherSuitesByName(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	for _, s := range tls.InsecureCipherSuites() {
+		known[s.Name] = s.ID
+	}
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %v", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
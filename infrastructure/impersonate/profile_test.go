@@ -7,6 +7,7 @@
 package impersonate
 
 import (
+	"crypto/tls"
 	"testing"
 )
 
@@ -100,3 +101,61 @@ func TestProfileByName(t *testing.T) {
 		})
 	}
 }
+
+func TestLookupProfile(t *testing.T) {
+	if _, ok := LookupProfile(profileNameFirefox); !ok {
+		t.Error("expected firefox to be a known profile")
+	}
+	if _, ok := LookupProfile("edge"); ok {
+		t.Error("expected edge to be unknown")
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	p := FirefoxProfile()
+	c := p.TLSConfig()
+	if len(c.CurvePreferences) != len(firefoxCurves) {
+		t.Errorf("expected %d curves, got %d", len(firefoxCurves), len(c.CurvePreferences))
+	}
+	if c.InsecureSkipVerify {
+		t.Error("certificate checks should stay enabled")
+	}
+}
+
+func TestCustomProfile(t *testing.T) {
+	p, err := ProfileConfig{
+		Name:         "custom",
+		Base:         profileNameSafari,
+		CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+		Curves:       []string{"P256"},
+		Headers:      map[string]string{"user-agent": "custom/1.0"},
+	}.Profile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.Name != "custom" {
+		t.Errorf("expected name 'custom', got %q", p.Name)
+	}
+	if len(p.CipherSuites) != 1 || p.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("unexpected cipher suites: %v", p.CipherSuites)
+	}
+	if len(p.CurvePreferences) != 1 || p.CurvePreferences[0] != tls.CurveP256 {
+		t.Errorf("unexpected curves: %v", p.CurvePreferences)
+	}
+	if p.DefaultHeaders.Get("User-Agent") != "custom/1.0" {
+		t.Errorf("expected the User-Agent to be overridden, got %q", p.DefaultHeaders.Get("User-Agent"))
+	}
+	if p.DefaultHeaders.Get("Accept-Language") == "" {
+		t.Error("expected the base profile headers to be inherited")
+	}
+
+	if _, err := (ProfileConfig{Name: "bad", Curves: []string{"P999"}}).Profile(); err == nil {
+		t.Error("expected an unknown curve to be rejected")
+	}
+	if _, err := (ProfileConfig{Name: "bad", CipherSuites: []string{"NOPE"}}).Profile(); err == nil {
+		t.Error("expected an unknown cipher suite to be rejected")
+	}
+	if _, err := (ProfileConfig{}).Profile(); err == nil {
+		t.Error("expected a profile without a name to be rejected")
+	}
+}
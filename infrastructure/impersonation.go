@@ -0,0 +1,112 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"fmt"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gobwas/glob"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+
+	"github.com/siemens/link-checker-service/infrastructure/impersonate"
+)
+
+// ImpersonateDomainConfig is unmarshalled from the configuration file
+type ImpersonateDomainConfig struct {
+	Domain  string
+	Profile string
+}
+
+type impersonateDomainRule struct {
+	domain  glob.Glob
+	profile string
+}
+
+func loadImpersonationFromViper(s *urlCheckerSettings) {
+	s.ImpersonateProfiles = map[string]impersonate.Profile{}
+
+	var customProfiles []impersonate.ProfileConfig
+	if err := viper.UnmarshalKey("impersonateProfiles", &customProfiles); err != nil {
+		panic(fmt.Errorf("could not parse the impersonateProfiles configuration: %v", err.Error()))
+	}
+	for _, profileConfig := range customProfiles {
+		profile, err := profileConfig.Profile()
+		if err != nil {
+			panic(fmt.Errorf("could not parse the impersonation profile: %v", err.Error()))
+		}
+		s.ImpersonateProfiles[profile.Name] = profile
+		log.Info().Msgf("Custom impersonation profile defined: '%v'", profile.Name)
+	}
+
+	if v := viper.GetString("HTTPClient.impersonateProfile"); v != "" {
+		s.ImpersonateProfile = v
+		mustBeKnownProfile(*s, v)
+		log.Info().Msgf("HTTP client ImpersonateProfile: %v", v)
+	}
+
+	var domainConfigs []ImpersonateDomainConfig
+	if err := viper.UnmarshalKey("impersonateDomains", &domainConfigs); err != nil {
+		panic(fmt.Errorf("could not parse the impersonateDomains configuration: %v", err.Error()))
+	}
+	for _, domainConfig := range domainConfigs {
+		mustBeKnownProfile(*s, domainConfig.Profile)
+		s.ImpersonateDomains = append(s.ImpersonateDomains, impersonateDomainRule{
+			domain:  glob.MustCompile(domainConfig.Domain),
+			profile: domainConfig.Profile,
+		})
+		log.Info().Msgf("Impersonating '%v' for domains matching '%v'", domainConfig.Profile, domainConfig.Domain)
+	}
+}
+
+func mustBeKnownProfile(s urlCheckerSettings, name string) {
+	if _, ok := s.profileByName(name); !ok {
+		panic(fmt.Errorf("unknown impersonation profile: %v", name))
+	}
+}
+
+func (s urlCheckerSettings) profileByName(name string) (impersonate.Profile, bool) {
+	// custom profiles may shadow the built-in ones
+	if p, ok := s.ImpersonateProfiles[name]; ok {
+		return p, true
+	}
+	return impersonate.LookupProfile(name)
+}
+
+// impersonationFor picks the first matching domain rule, falling back to the global profile, if any
+func (s urlCheckerSettings) impersonationFor(urlToCheck string) *impersonate.Profile {
+	name := s.ImpersonateProfile
+	if len(s.ImpersonateDomains) > 0 {
+		domain := DomainOf(urlToCheck)
+		for _, rule := range s.ImpersonateDomains {
+			if rule.domain.Match(domain) {
+				name = rule.profile
+				break
+			}
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	p, ok := s.profileByName(name)
+	if !ok {
+		return nil
+	}
+	return &p
+}
+
+// applyImpersonation sets the default headers of the profile. Its TLS settings are applied by the pooled transport.
+func applyImpersonation(client *resty.Client, settings urlCheckerSettings) {
+	p := settings.Impersonation
+	if p == nil {
+		return
+	}
+	for k, v := range p.DefaultHeaders {
+		client.Header[k] = v
+	}
+}
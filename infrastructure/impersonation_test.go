@@ -0,0 +1,72 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/siemens/link-checker-service/infrastructure/impersonate"
+)
+
+func TestImpersonationProfilesPerDomain(t *testing.T) {
+	var mu sync.Mutex
+	var userAgents []string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		userAgents = append(userAgents, r.Header.Get("User-Agent"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.skipCertificateCheck", true)
+
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, []string{defaultUserAgent}, userAgents, "no profile should be applied by default")
+
+	userAgents = nil
+	viper.Set("impersonateDomains", []ImpersonateDomainConfig{
+		{Domain: "127.0.0.?", Profile: "firefox"},
+	})
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, []string{impersonate.FirefoxProfile().DefaultHeaders.Get("User-Agent")}, userAgents)
+
+	userAgents = nil
+	viper.Set("impersonateProfiles", []impersonate.ProfileConfig{
+		{Name: "custom", Headers: map[string]string{"User-Agent": "custom/1.0"}},
+	})
+	viper.Set("impersonateDomains", []ImpersonateDomainConfig{
+		{Domain: "127.0.0.?", Profile: "custom"},
+	})
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, []string{"custom/1.0"}, userAgents)
+}
+
+func TestImpersonationFallsBackToTheGlobalProfile(t *testing.T) {
+	s := urlCheckerSettings{ImpersonateProfile: "safari"}
+	assert.Equal(t, "safari", s.impersonationFor("https://example.com").Name)
+	assert.Nil(t, urlCheckerSettings{}.impersonationFor("https://example.com"))
+}
+
+func TestUnknownImpersonationProfilesAreRejected(t *testing.T) {
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.impersonateProfile", "netscape")
+	assert.Panics(t, func() {
+		NewURLCheckerClient()
+	})
+}
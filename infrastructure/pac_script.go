@@ -0,0 +1,217 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	netUrl "net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/darren/gpac"
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+const defaultPacScriptRefreshInterval = 5 * time.Minute
+
+// pacScriptRetryInterval is the minimum delay between attempts to load a PAC script that never loaded
+const pacScriptRetryInterval = 10 * time.Second
+
+// pacScriptStartupWait bounds the wait for the first load of the PAC script at startup
+const pacScriptStartupWait = 2 * time.Second
+
+// PACStatus reports the health of the PAC script
+type PACStatus struct {
+	Source string
+	// Loaded is true if a valid version of the PAC script is in use
+	Loaded bool
+	// LastRefresh is the time the PAC script was last fetched successfully
+	LastRefresh time.Time
+	// LastError is the error of the last fetch attempt, while the last good version stays in use
+	LastError string
+}
+
+// pacScript keeps the last good version of a PAC script fetched via HTTP(S) or read from a file://
+// path, and refreshes it lazily on use, when the refresh interval has passed.
+type pacScript struct {
+	source          string
+	refreshInterval time.Duration
+	// client fetches the script via HTTP(S), limited by the timeout of the checks
+	client *resty.Client
+
+	mu           sync.RWMutex
+	parser       *gpac.Parser
+	etag         string
+	script       string
+	lastAttempt  time.Time
+	lastRefresh  time.Time
+	lastError    error
+	isRefreshing atomic.Bool
+}
+
+// newPacScript starts loading the PAC script, waiting for it up to pacScriptStartupWait
+func newPacScript(source string, refreshInterval, fetchTimeout time.Duration) *pacScript {
+	p := &pacScript{
+		source:          source,
+		refreshInterval: refreshInterval,
+		client:          resty.New().SetTimeout(fetchTimeout),
+	}
+	p.isRefreshing.Store(true)
+	loaded := make(chan struct{})
+	go func() {
+		defer close(loaded)
+		defer p.isRefreshing.Store(false)
+		p.refresh()
+	}()
+	select {
+	case <-loaded:
+	case <-time.After(pacScriptStartupWait):
+	}
+	if s := p.status(); !s.Loaded {
+		log.Error().Msgf("Could not load the PAC script from %v yet (%v), dropping the checks until it is available", redactedURL(p.source), s.LastError)
+	}
+	return p
+}
+
+// errPACScriptNotLoaded is returned while no version of the PAC script has ever been loaded
+var errPACScriptNotLoaded = errors.New("the PAC script has not been loaded yet")
+
+// FindProxy returns the proxies for the URL, or errPACScriptNotLoaded if no PAC script has been loaded yet
+func (p *pacScript) FindProxy(urlToCheck string) ([]*gpac.Proxy, error) {
+	p.maybeRefreshInBackground()
+	p.mu.RLock()
+	parser := p.parser
+	p.mu.RUnlock()
+	if parser == nil {
+		return nil, errPACScriptNotLoaded
+	}
+	return parser.FindProxy(urlToCheck)
+}
+
+func (p *pacScript) status() PACStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	s := PACStatus{
+		Source:      redactedURL(p.source),
+		Loaded:      p.parser != nil,
+		LastRefresh: p.lastRefresh,
+	}
+	if p.lastError != nil {
+		s.LastError = p.lastError.Error()
+	}
+	return s
+}
+
+// PACStatus reports the health of the PAC script, nil if none is configured
+func (c *URLCheckerClient) PACStatus() *PACStatus {
+	if c.autoProxy == nil {
+		return nil
+	}
+	s := c.autoProxy.status()
+	return &s
+}
+
+func redactedURL(url string) string {
+	u, err := netUrl.Parse(url)
+	if err != nil {
+		return "<bad url>"
+	}
+	return u.Redacted()
+}
+
+func (p *pacScript) refreshIsDue() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.parser == nil {
+		return time.Since(p.lastAttempt) >= pacScriptRetryInterval
+	}
+	return p.refreshInterval > 0 && time.Since(p.lastAttempt) >= p.refreshInterval
+}
+
+func (p *pacScript) maybeRefreshInBackground() {
+	if !p.refreshIsDue() || !p.isRefreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer p.isRefreshing.Store(false)
+		p.refresh()
+	}()
+}
+
+// refresh fetches the script, and replaces the parser if the script changed. On failure, the last good version is kept.
+func (p *pacScript) refresh() {
+	p.mu.RLock()
+	etag := p.etag
+	p.mu.RUnlock()
+
+	script, newEtag, notModified, err := p.fetch(etag)
+	var parser *gpac.Parser
+	if err == nil && !notModified && script != p.currentScript() {
+		parser, err = gpac.New(script)
+		if err != nil {
+			err = fmt.Errorf("could not parse the PAC script: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastAttempt = time.Now()
+	p.lastError = err
+	if err != nil {
+		log.Warn().Err(err).Msgf("Could not refresh the PAC script from %v", redactedURL(p.source))
+		return
+	}
+	p.lastRefresh = p.lastAttempt
+	p.etag = newEtag
+	if parser != nil {
+		p.parser = parser
+		p.script = script
+		log.Info().Msgf("Read PAC script from %v", redactedURL(p.source))
+	}
+}
+
+func (p *pacScript) currentScript() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.script
+}
+
+func (p *pacScript) fetch(etag string) (script string, newEtag string, notModified bool, err error) {
+	u, err := netUrl.Parse(p.source)
+	if err != nil {
+		return "", "", false, err
+	}
+	if u.Scheme == "file" {
+		content, err := os.ReadFile(u.Path)
+		if err != nil {
+			return "", "", false, err
+		}
+		return string(content), "", false, nil
+	}
+
+	req := p.client.R()
+	if etag != "" {
+		req.SetHeader("If-None-Match", etag)
+	}
+	res, err := req.Get(p.source)
+	if err != nil {
+		return "", "", false, err
+	}
+	switch res.StatusCode() {
+	case http.StatusOK:
+		return string(res.Body()), res.Header().Get("ETag"), false, nil
+	case http.StatusNotModified:
+		return "", etag, true, nil
+	default:
+		return "", "", false, fmt.Errorf("%v status fetching the PAC script", res.StatusCode())
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pacScriptReturning(result string) string {
+	return fmt.Sprintf(`function FindProxyForURL(url, host) { return "%v"; }`, result)
+}
+
+func proxyTypesOf(t *testing.T, p *pacScript) []string {
+	proxies, err := p.FindProxy("https://example.com")
+	require.NoError(t, err)
+	var types []string
+	for _, proxy := range proxies {
+		types = append(types, proxy.Type)
+	}
+	return types
+}
+
+func TestPACScriptsFromFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxy.pac")
+	require.NoError(t, os.WriteFile(path, []byte(pacScriptReturning("DIRECT")), 0o600))
+
+	p := newPacScript("file://"+path, 0, time.Second)
+	assert.Equal(t, []string{"DIRECT"}, proxyTypesOf(t, p))
+
+	require.NoError(t, os.WriteFile(path, []byte(pacScriptReturning("PROXY proxy:8080")), 0o600))
+	p.refresh()
+	assert.Equal(t, []string{"PROXY"}, proxyTypesOf(t, p))
+
+	require.NoError(t, os.WriteFile(path, []byte("function FindProxyForURL(url, host) {"), 0o600))
+	p.refresh()
+	assert.Equal(t, []string{"PROXY"}, proxyTypesOf(t, p), "the last good version should be kept")
+	assert.Contains(t, p.status().LastError, "could not parse")
+}
+
+func TestPACScriptRefreshes(t *testing.T) {
+	var m sync.Mutex
+	script, etag, failing := pacScriptReturning("DIRECT"), `"v1"`, false
+	var conditionalRequests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.Lock()
+		defer m.Unlock()
+		switch {
+		case failing:
+			w.WriteHeader(http.StatusInternalServerError)
+		case r.Header.Get("If-None-Match") == etag:
+			conditionalRequests++
+			w.WriteHeader(http.StatusNotModified)
+		default:
+			w.Header().Set("ETag", etag)
+			_, _ = fmt.Fprint(w, script)
+		}
+	}))
+	defer ts.Close()
+	update := func(f func()) {
+		m.Lock()
+		defer m.Unlock()
+		f()
+	}
+
+	p := newPacScript(ts.URL, 10*time.Millisecond, time.Second)
+	assert.Equal(t, []string{"DIRECT"}, proxyTypesOf(t, p))
+	firstRefresh := p.status().LastRefresh
+
+	p.refresh()
+	update(func() { assert.Equal(t, 1, conditionalRequests) })
+	assert.True(t, p.status().LastRefresh.After(firstRefresh))
+
+	update(func() { failing = true })
+	p.refresh()
+	assert.Equal(t, []string{"DIRECT"}, proxyTypesOf(t, p), "the last good version should be kept")
+	status := p.status()
+	assert.True(t, status.Loaded)
+	assert.Contains(t, status.LastError, "500")
+
+	update(func() { script, etag, failing = pacScriptReturning("PROXY proxy:8080; DIRECT"), `"v2"`, false })
+	assert.Eventually(t, func() bool {
+		types, _ := p.FindProxy("https://example.com")
+		return len(types) == 2
+	}, 2*time.Second, 20*time.Millisecond, "the script should be refreshed in the background")
+	assert.Empty(t, p.status().LastError)
+}
+
+func TestUnresponsivePACServersDoNotBlockTheStartup(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	start := time.Now()
+	p := newPacScript(ts.URL, time.Minute, time.Minute)
+	assert.Less(t, time.Since(start), pacScriptStartupWait+time.Second, "the wait for the first load is bounded")
+	assert.False(t, p.status().Loaded)
+
+	p = newPacScript(ts.URL, time.Minute, 300*time.Millisecond)
+	assert.False(t, p.status().Loaded)
+
+	assert.Eventually(t, func() bool {
+		return p.status().LastError != "" && !p.isRefreshing.Load()
+	}, 2*time.Second, 20*time.Millisecond, "the fetch should time out, allowing later refreshes")
+	_, err := p.FindProxy("https://example.com")
+	assert.Error(t, err)
+}
+
+func TestUnavailablePACScriptsDoNotBlockTheStartup(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	pacFile := filepath.Join(t.TempDir(), "missing.pac")
+	setUpViperTestConfiguration()
+	viper.Set("pacScriptURL", "file://"+pacFile)
+	viper.Set("urlCheckerPlugins", []string{"urlcheck-pac"})
+
+	c := NewURLCheckerClient()
+	status := c.PACStatus()
+	require.NotNil(t, status)
+	assert.False(t, status.Loaded)
+	assert.NotEmpty(t, status.LastError)
+
+	res := c.CheckURL(context.Background(), target.URL)
+	assert.Equal(t, Dropped, res.Status, "no route should be guessed before the PAC script is loaded")
+	assert.ErrorIs(t, res.Error, errPACScriptNotLoaded)
+
+	require.NoError(t, os.WriteFile(pacFile, []byte(pacScriptReturning("DIRECT")), 0o600))
+	c.autoProxy.refresh()
+	res = c.CheckURL(context.Background(), target.URL)
+	assert.Equal(t, Ok, res.Status, "the checks should resume once the PAC script is loaded")
+}
@@ -0,0 +1,201 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	netUrl "net/url"
+	"time"
+
+	"github.com/darren/gpac"
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+// directConnection is the label of the PAC route not using a proxy
+const directConnection = "DIRECT"
+
+func isSupportedProxyScheme(scheme string) bool {
+	switch scheme {
+	case "http", "https", "socks5", "socks5h":
+		return true
+	default:
+		return false
+	}
+}
+
+// proxyCredentials are attached to the proxy URLs without user info. They must never be logged.
+type proxyCredentials struct {
+	username string
+	password string
+}
+
+// loadProxyCredentialsFromViper resolves the proxy credentials, which may reference a secret via "env:<VARIABLE>" or "file:<path>"
+func loadProxyCredentialsFromViper(s *urlCheckerSettings) {
+	username, err := secretOf(viper.GetString("proxyUsername"))
+	if err != nil {
+		panic(fmt.Errorf("could not load the proxy username: %v", err.Error()))
+	}
+	password, err := secretOf(viper.GetString("proxyPassword"))
+	if err != nil {
+		panic(fmt.Errorf("could not load the proxy password: %v", err.Error()))
+	}
+	if username == "" && password == "" {
+		return
+	}
+	s.ProxyCredentials = &proxyCredentials{username: username, password: password}
+	log.Info().Msg("Proxy credentials are configured")
+}
+
+// applyTo wraps the proxy selection of a transport, for the transport to authenticate
+// CONNECT and plain requests, or the SOCKS5 handshake
+func (p *proxyCredentials) applyTo(proxy func(*http.Request) (*netUrl.URL, error)) func(*http.Request) (*netUrl.URL, error) {
+	if p == nil {
+		return proxy
+	}
+	return func(req *http.Request) (*netUrl.URL, error) {
+		u, err := proxy(req)
+		if err != nil || u == nil || u.User != nil {
+			return u, err
+		}
+		withCredentials := *u
+		withCredentials.User = netUrl.UserPassword(p.username, p.password)
+		return &withCredentials, nil
+	}
+}
+
+// proxyAuthRequiredResult reports a proxy rejecting the credentials as an issue of the checker, not of the link
+func proxyAuthRequiredResult(err error, nowEpoch int64) *URLCheckResult {
+	return &URLCheckResult{
+		Status:                Dropped,
+		Code:                  http.StatusProxyAuthRequired,
+		Error:                 err,
+		FetchedAtEpochSeconds: nowEpoch,
+		BodyPatternsFound:     []string{},
+		ErrorCategory:         ErrorCategoryProxyAuthRequired,
+	}
+}
+
+// proxyURLOf maps a PAC script entry to the proxy URL of a transport, empty for a direct connection
+func proxyURLOf(p *gpac.Proxy) (string, bool) {
+	var scheme string
+	switch p.Type {
+	case directConnection:
+		return "", true
+	case "PROXY", "HTTP":
+		scheme = "http"
+	case "HTTPS":
+		scheme = "https"
+	case "SOCKS", "SOCKS5":
+		scheme = "socks5"
+	default:
+		return "", false
+	}
+	u := &netUrl.URL{Scheme: scheme, Host: p.Address}
+	if p.Username != "" {
+		u.User = netUrl.UserPassword(p.Username, p.Password)
+	}
+	return u.String(), true
+}
+
+// proxyLabelOf names the proxy in the check trace without its credentials
+func proxyLabelOf(proxyURL string) string {
+	if proxyURL == "" {
+		return directConnection
+	}
+	u, err := netUrl.Parse(proxyURL)
+	if err != nil {
+		return "<bad proxy url>"
+	}
+	return u.Redacted()
+}
+
+// pacRoutesFor returns the proxy URLs in the order returned by the PAC script, falling back to a direct connection.
+// Without a loaded PAC script, the route is unknown: errPACScriptNotLoaded is returned
+func (c *URLCheckerClient) pacRoutesFor(urlToCheck string) ([]string, error) {
+	// as browsers do, the PAC script sees the http(s) URL of a WebSocket handshake
+	proxies, err := c.autoProxy.FindProxy(handshakeURLOf(urlToCheck))
+	if errors.Is(err, errPACScriptNotLoaded) {
+		return nil, err
+	}
+	if err != nil {
+		log.Warn().Msgf("Could not find a proxy for %v", sanitizeUserLogInput(urlToCheck))
+		return []string{""}, nil
+	}
+	var routes []string
+	for _, proxy := range proxies {
+		route, ok := proxyURLOf(proxy)
+		if !ok {
+			log.Debug().Msgf("Ignoring the unsupported PAC entry type %v", proxy.Type)
+			continue
+		}
+		routes = append(routes, route)
+	}
+	if len(routes) == 0 {
+		return []string{""}, nil
+	}
+	return routes, nil
+}
+
+// isProxyUnreachable is true if no connection to the proxy could be established, i.e. the next one can be tried
+func isProxyUnreachable(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "proxyconnect"
+}
+
+// pacClientKey identifies a client built for a route of the PAC script
+type pacClientKey struct {
+	transportKey
+	timeoutSeconds uint
+}
+
+func (l *localURLChecker) pacClientFor(urlToCheck string, proxyURL string, policy *checkPolicy) *resty.Client {
+	tmpSettings := l.c.settings
+	tmpSettings.Impersonation = tmpSettings.impersonationFor(urlToCheck)
+	tmpSettings.ProxyURL = proxyURL
+	tmpSettings.TimeoutSeconds = policy.timeoutOr(tmpSettings.TimeoutSeconds)
+	key := pacClientKey{transportKey: transportKeyOf(tmpSettings), timeoutSeconds: tmpSettings.TimeoutSeconds}
+	if client, ok := l.pacClients.Load(key); ok {
+		return client.(*resty.Client)
+	}
+	client, _ := l.pacClients.LoadOrStore(key, buildClient(tmpSettings))
+	return client.(*resty.Client)
+}
+
+// checkViaPACRoutes tries the routes returned by the PAC script in order, until a proxy could be connected to
+func (l *localURLChecker) checkViaPACRoutes(ctx context.Context, urlToCheck string) (*URLCheckResult, bool) {
+	routes, err := l.c.pacRoutesFor(urlToCheck)
+	if err != nil {
+		// not cached: the URL is checked via the right route once the PAC script is available
+		GlobalStats().OnLinkDropped(DomainOf(urlToCheck))
+		res := droppedResult(time.Now().Unix(), err)
+		res.BodyPatternsFound = []string{}
+		return res, false
+	}
+	policy := checkPolicyOf(ctx)
+	var attempts []URLCheckerPluginTrace
+	for i, route := range routes {
+		start := time.Now()
+		res, shouldAbort := l.checkWithRetries(ctx, urlToCheck, l.pacClientFor(urlToCheck, route, policy), proxyLabelOf(route))
+		if len(res.attempts) > 0 {
+			attempts = append(attempts, res.attempts...)
+		} else {
+			attempts = append(attempts, checkerTraceEntry(l, res, time.Since(start)))
+		}
+		res.attempts = attempts
+		if shouldAbort || i == len(routes)-1 || !isProxyUnreachable(res.Error) {
+			return res, shouldAbort
+		}
+		log.Debug().Msgf("Proxy %v unreachable, trying the next one for %v", proxyLabelOf(route), sanitizeUserLogInput(urlToCheck))
+	}
+	panic("no PAC routes to check")
+}
@@ -0,0 +1,49 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// countingReader counts the bytes read from the response body
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// acceptingUnsatisfiableRange treats 416 as success, as the server acknowledged the resource, e.g. an empty file
+func acceptingUnsatisfiableRange(res *URLCheckResult) *URLCheckResult {
+	if res.Status == Broken && res.Code == http.StatusRequestedRangeNotSatisfiable {
+		res.Status = Ok
+		res.Error = nil
+	}
+	return res
+}
+
+// drainRangeResponse reads the small remainder of a partial response, so that the connection may be reused.
+// Servers ignoring the range would send the complete body, thus the connection is closed right after the headers.
+func (c *URLCheckerClient) drainRangeResponse(response *resty.Response, body io.Reader) {
+	if response == nil || response.RawResponse == nil {
+		return
+	}
+	limit := int64(c.settings.RangeFallbackBytes)
+	contentLength := response.RawResponse.ContentLength
+	if response.StatusCode() != http.StatusPartialContent && (contentLength < 0 || contentLength > limit) {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, limit))
+}
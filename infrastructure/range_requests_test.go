@@ -0,0 +1,96 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const largeFileSize = 8 * 1024 * 1024
+
+func fileServer(t *testing.T, ranges *[]string) *httptest.Server {
+	var m sync.Mutex
+	large := bytes.Repeat([]byte("x"), largeFileSize)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		m.Lock()
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		m.Unlock()
+		switch r.URL.Path {
+		case "/image.iso":
+			http.ServeContent(w, r, "image.iso", time.Time{}, bytes.NewReader(large))
+		case "/empty.txt":
+			// as answered by some servers for empty files
+			w.Header().Set("Content-Range", "bytes */0")
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		case "/ignoring-ranges.iso":
+			_, _ = w.Write(large)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRangedGetFallback(t *testing.T) {
+	var ranges []string
+	ts := fileServer(t, &ranges)
+	setUpViperTestConfiguration()
+	c := NewURLCheckerClient()
+
+	tests := []struct {
+		path  string
+		code  int
+		bytes int64
+	}{
+		{"/image.iso", http.StatusPartialContent, defaultRangeFallbackBytes},
+		{"/empty.txt", http.StatusRequestedRangeNotSatisfiable, 0},
+		{"/ignoring-ranges.iso", http.StatusOK, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			ranges = nil
+			ResetGlobalStats()
+			res := c.CheckURL(context.Background(), ts.URL+tt.path)
+			assert.Equal(t, Ok, res.Status, "%v", res.Error)
+			assert.Equal(t, tt.code, res.Code)
+			assert.Equal(t, []string{"bytes=0-1023"}, ranges)
+			require.Len(t, res.CheckerTrace, 1)
+			assert.Equal(t, tt.bytes, res.CheckerTrace[0].BytesTransferred)
+			assert.Equal(t, tt.bytes, GlobalStats().GetStats().BytesTransferred)
+			assert.Equal(t, tt.bytes, GlobalStats().GetDomainStats().DomainStats["127.0.0.1"].BytesTransferred)
+		})
+	}
+}
+
+func TestNoRangesAreRequestedWhenSearchingForBodyPatterns(t *testing.T) {
+	var ranges []string
+	ts := fileServer(t, &ranges)
+	setUpViperTestConfiguration()
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{{Name: "missing", Regex: "missing"}})
+
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/image.iso")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, []string{""}, ranges)
+	assert.Equal(t, int64(largeFileSize), res.BytesTransferred)
+	assert.Equal(t, int64(largeFileSize), res.BodyBytesInspected)
+}
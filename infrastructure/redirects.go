@@ -0,0 +1,108 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// URLRedirect is the internal struct to hold a single redirect hop
+type URLRedirect struct {
+	URL       string
+	Code      int
+	Location  string
+	ElapsedMs int64
+	// Permanent is set for 301 and 308 redirects, i.e. the link should be updated to the Location
+	Permanent bool
+}
+
+type redirectRecorderKey struct{}
+
+// redirectRecorder collects the redirect hops of a single request
+type redirectRecorder struct {
+	mu        sync.Mutex
+	lastHop   time.Time
+	redirects []URLRedirect
+}
+
+func withRedirectRecorder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, redirectRecorderKey{}, &redirectRecorder{lastHop: time.Now()})
+}
+
+func redirectRecorderOf(ctx context.Context) *redirectRecorder {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(redirectRecorderKey{}).(*redirectRecorder)
+	return r
+}
+
+func (r *redirectRecorder) record(from string, response *http.Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.redirects = append(r.redirects, URLRedirect{
+		URL:       from,
+		Code:      response.StatusCode,
+		Location:  response.Header.Get("Location"),
+		ElapsedMs: int64(now.Sub(r.lastHop) / time.Millisecond),
+		Permanent: response.StatusCode == http.StatusMovedPermanently || response.StatusCode == http.StatusPermanentRedirect,
+	})
+	r.lastHop = now
+}
+
+func (r *redirectRecorder) recorded() []URLRedirect {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.redirects) == 0 {
+		return nil
+	}
+	return append([]URLRedirect(nil), r.redirects...)
+}
+
+// maxRedirectsPolicy is resty's FlexibleRedirectPolicy failing with a typed error
+func maxRedirectsPolicy(maxRedirects int) resty.RedirectPolicy {
+	flexible := resty.FlexibleRedirectPolicy(maxRedirects)
+	return resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return &tooManyRedirectsError{max: maxRedirects}
+		}
+		return flexible.Apply(req, via)
+	})
+}
+
+// recordingRedirectPolicy stores each hop in the recorder found in the request context, if any
+func recordingRedirectPolicy() resty.RedirectPolicy {
+	return resty.RedirectPolicyFunc(func(req *http.Request, via []*http.Request) error {
+		recorder := redirectRecorderOf(req.Context())
+		if recorder != nil && req.Response != nil && len(via) > 0 {
+			recorder.record(via[len(via)-1].URL.String(), req.Response)
+		}
+		return nil
+	})
+}
+
+// withRedirectsOf attaches the recorded redirect chain and the final URL of the response to the result
+func withRedirectsOf(res *URLCheckResult, response *resty.Response) *URLCheckResult {
+	if response == nil || response.Request == nil {
+		return res
+	}
+	if recorder := redirectRecorderOf(response.Request.Context()); recorder != nil {
+		res.Redirects = recorder.recorded()
+	}
+	if response.RawResponse != nil && response.RawResponse.Request != nil {
+		res.FinalURL = response.RawResponse.Request.URL.String()
+	} else if n := len(res.Redirects); n > 0 {
+		res.FinalURL = res.Redirects[n-1].Location
+	}
+	return res
+}
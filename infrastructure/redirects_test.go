@@ -0,0 +1,60 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func redirectingTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/old", http.RedirectHandler("/moved", http.StatusMovedPermanently))
+	mux.Handle("/moved", http.RedirectHandler("/new", http.StatusFound))
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRecordingRedirectChains(t *testing.T) {
+	ts := redirectingTestServer()
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/old")
+	assert.Equal(t, Ok, res.Status)
+	require.Len(t, res.Redirects, 2)
+	assert.Equal(t, ts.URL+"/old", res.Redirects[0].URL)
+	assert.Equal(t, http.StatusMovedPermanently, res.Redirects[0].Code)
+	assert.Equal(t, "/moved", res.Redirects[0].Location)
+	assert.True(t, res.Redirects[0].Permanent)
+	assert.Equal(t, ts.URL+"/moved", res.Redirects[1].URL)
+	assert.False(t, res.Redirects[1].Permanent)
+	assert.Equal(t, ts.URL+"/new", res.FinalURL)
+
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/new")
+	assert.Nil(t, res.Redirects)
+	assert.Equal(t, ts.URL+"/new", res.FinalURL)
+}
+
+func TestMaxRedirectsCountIsHonoured(t *testing.T) {
+	ts := redirectingTestServer()
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.maxRedirectsCount", uint(1))
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/old")
+	assert.Equal(t, Broken, res.Status)
+	assert.NotNil(t, res.Error)
+}
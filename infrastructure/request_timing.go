@@ -0,0 +1,195 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const defaultConnectTimeout = 30 * time.Second
+const defaultTLSHandshakeTimeout = 10 * time.Second
+
+// phaseTimeouts limit the individual phases of a request, in addition to the overall TimeoutSeconds. 0 disables a limit.
+type phaseTimeouts struct {
+	// Connect includes the DNS resolution
+	Connect        time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+	// BodyRead limits reading the response body, after which the body is treated as complete
+	BodyRead time.Duration
+}
+
+func loadPhaseTimeoutsFromViper(s *urlCheckerSettings) {
+	s.Timeouts = phaseTimeouts{
+		Connect:      defaultConnectTimeout,
+		TLSHandshake: defaultTLSHandshakeTimeout,
+	}
+	if viper.GetString("HTTPClient.connectTimeout") != "" {
+		s.Timeouts.Connect = viperDuration("HTTPClient.connectTimeout", defaultConnectTimeout)
+	}
+	if viper.GetString("HTTPClient.tlsHandshakeTimeout") != "" {
+		s.Timeouts.TLSHandshake = viperDuration("HTTPClient.tlsHandshakeTimeout", defaultTLSHandshakeTimeout)
+	}
+	if viper.GetString("HTTPClient.responseHeaderTimeout") != "" {
+		s.Timeouts.ResponseHeader = viperDuration("HTTPClient.responseHeaderTimeout", 0)
+	}
+	if viper.GetString("HTTPClient.bodyReadTimeout") != "" {
+		s.Timeouts.BodyRead = viperDuration("HTTPClient.bodyReadTimeout", 0)
+	}
+	log.Info().Msgf("HTTP client phase timeouts: connect %v, TLS handshake %v, response header %v, body read %v",
+		s.Timeouts.Connect, s.Timeouts.TLSHandshake, s.Timeouts.ResponseHeader, s.Timeouts.BodyRead)
+}
+
+// RequestTiming breaks down the duration of the request a result is based on. Phases that did not happen,
+// e.g. connecting on a kept-alive connection, are 0.
+type RequestTiming struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	// TimeToFirstByte is measured from the start of the request
+	TimeToFirstByte time.Duration
+	// Total includes reading the response body, if any
+	Total time.Duration
+}
+
+type requestTimerKey struct{}
+
+// requestTimer records the phases of a request via httptrace
+type requestTimer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dns          time.Duration
+	connectStart time.Time
+	connect      time.Duration
+	tlsStart     time.Time
+	tls          time.Duration
+	firstByte    time.Duration
+}
+
+func withRequestTimer(ctx context.Context) context.Context {
+	timer := &requestTimer{start: time.Now()}
+	ctx = context.WithValue(ctx, requestTimerKey{}, timer)
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { timer.record(func() { timer.dnsStart = time.Now() }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { timer.record(func() { timer.dns = time.Since(timer.dnsStart) }) },
+		ConnectStart: func(string, string) {
+			timer.record(func() {
+				// the first of the parallel attempts of a dual-stack dial
+				if timer.connectStart.IsZero() {
+					timer.connectStart = time.Now()
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				timer.record(func() { timer.connect = time.Since(timer.connectStart) })
+			}
+		},
+		TLSHandshakeStart: func() { timer.record(func() { timer.tlsStart = time.Now() }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timer.record(func() { timer.tls = time.Since(timer.tlsStart) })
+		},
+		GotFirstResponseByte: func() { timer.record(func() { timer.firstByte = time.Since(timer.start) }) },
+	})
+}
+
+func (t *requestTimer) record(f func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	f()
+}
+
+// timingOf returns the timing of the request of the response up to now, nil if it was not timed
+func timingOf(response *resty.Response) *RequestTiming {
+	if response == nil || response.Request == nil {
+		return nil
+	}
+	timer, ok := response.Request.Context().Value(requestTimerKey{}).(*requestTimer)
+	if !ok {
+		return nil
+	}
+	timer.mu.Lock()
+	defer timer.mu.Unlock()
+	return &RequestTiming{
+		DNS:             timer.dns,
+		Connect:         timer.connect,
+		TLS:             timer.tls,
+		TimeToFirstByte: timer.firstByte,
+		Total:           time.Since(timer.start),
+	}
+}
+
+type dialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// dialContextWithTimeout limits the time to resolve the host name and to connect
+func dialContextWithTimeout(dial dialContextFunc, timeout time.Duration) dialContextFunc {
+	if timeout <= 0 {
+		return dial
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return dial(ctx, network, address)
+	}
+}
+
+var errBodyReadTimeout = errors.New("body read timeout")
+
+// bodyWithReadTimeout closes the response body once the body read timeout has passed,
+// for a pending read to fail instead of blocking
+type bodyWithReadTimeout struct {
+	body  io.ReadCloser
+	timer *time.Timer
+
+	mu       sync.Mutex
+	timedOut bool
+}
+
+// bodyOf returns the raw body of a response the configured body read timeout applies to
+func (c *URLCheckerClient) bodyOf(response *resty.Response) io.ReadCloser {
+	body := response.RawBody()
+	if body == nil || c.settings.Timeouts.BodyRead <= 0 {
+		return body
+	}
+	b := &bodyWithReadTimeout{body: body}
+	b.timer = time.AfterFunc(c.settings.Timeouts.BodyRead, func() {
+		b.mu.Lock()
+		b.timedOut = true
+		b.mu.Unlock()
+		_ = body.Close()
+	})
+	return b
+}
+
+func (b *bodyWithReadTimeout) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if err != nil {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.timedOut {
+			return n, errBodyReadTimeout
+		}
+	}
+	return n, err
+}
+
+func (b *bodyWithReadTimeout) Close() error {
+	b.timer.Stop()
+	return b.body.Close()
+}
@@ -0,0 +1,112 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// timingTestServer answers /slow-header after a delay, and streams the body of /slow-body
+// to GET requests until the request is canceled
+func timingTestServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow-header":
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		case "/slow-body":
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusOK)
+			for i := 0; ; i++ {
+				if _, err := fmt.Fprintf(w, "<p>%v</p>", i); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRequestTimingIsRecorded(t *testing.T) {
+	ts := timingTestServer(t)
+	setUpViperTestConfiguration()
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	require.NotNil(t, res.Timing)
+	assert.Greater(t, res.Timing.TimeToFirstByte, time.Duration(0))
+	assert.GreaterOrEqual(t, res.Timing.Total, res.Timing.TimeToFirstByte)
+	assert.Zero(t, res.Timing.TLS, "no TLS handshake on plain HTTP")
+	require.Len(t, res.CheckerTrace, 1)
+	assert.Equal(t, res.Timing, res.CheckerTrace[0].Timing)
+}
+
+func TestResponseHeaderTimeout(t *testing.T) {
+	ts := timingTestServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.responseHeaderTimeout", "200ms")
+	c := NewURLCheckerClient()
+
+	start := time.Now()
+	res := c.CheckURL(context.Background(), ts.URL+"/slow-header")
+	assert.Less(t, time.Since(start), time.Second, "the overall timeout does not apply")
+	assert.NotEqual(t, Ok, res.Status)
+	assert.Equal(t, ErrorCategoryTimeoutRead, res.ErrorCategory, "%v", res.Error)
+}
+
+func TestBodyReadTimeout(t *testing.T) {
+	ts := timingTestServer(t)
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.bodyReadTimeout", "300ms")
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{{Name: "paragraph", Regex: "<p>1</p>"}})
+	t.Cleanup(func() { viper.Set("bodyPatterns", nil) })
+	c := NewURLCheckerClient()
+
+	start := time.Now()
+	res := c.CheckURL(context.Background(), ts.URL+"/slow-body")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, []string{"paragraph"}, res.BodyPatternsFound, "the body read so far is searched")
+	assert.Greater(t, res.BodyBytesInspected, int64(0))
+}
+
+func TestLoadingPhaseTimeouts(t *testing.T) {
+	setUpViperTestConfiguration()
+	var s urlCheckerSettings
+	loadPhaseTimeoutsFromViper(&s)
+	assert.Equal(t, phaseTimeouts{Connect: defaultConnectTimeout, TLSHandshake: defaultTLSHandshakeTimeout}, s.Timeouts)
+
+	viper.Set("HTTPClient.connectTimeout", "0")
+	viper.Set("HTTPClient.bodyReadTimeout", "2s")
+	loadPhaseTimeoutsFromViper(&s)
+	assert.Zero(t, s.Timeouts.Connect)
+	assert.Equal(t, 2*time.Second, s.Timeouts.BodyRead)
+}
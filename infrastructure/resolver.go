@@ -0,0 +1,529 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const defaultDNSTimeout = 5 * time.Second
+
+// dialFallbackDelay is the delay before racing the addresses of the other address family, as the standard dialer does
+// (RFC 8305 Happy Eyeballs)
+const dialFallbackDelay = 300 * time.Millisecond
+
+// minDialAttemptTimeout is the minimum share of the connect timeout of each address tried in turn
+const minDialAttemptTimeout = 2 * time.Second
+const dnsMessageContentType = "application/dns-message"
+const maxDNSMessageSize = 65535
+
+// resolverSettings configure how host names are resolved by all checkers
+type resolverSettings struct {
+	// Server is the address of a DNS server to query instead of the system resolver, e.g. 10.0.0.53:53
+	Server string
+	// DoHURL is the URL of a DNS-over-HTTPS (RFC 8484) endpoint. Takes precedence over Server.
+	DoHURL string
+	// Overrides map "host:port" to static IP addresses, in the manner of curl's --resolve
+	Overrides map[string][]net.IP
+}
+
+func loadResolverFromViper(s *urlCheckerSettings) {
+	s.DNS = resolverSettings{
+		Server: viper.GetString("HTTPClient.dnsServer"),
+		DoHURL: viper.GetString("HTTPClient.dnsOverHTTPSURL"),
+	}
+	if s.DNS.Server != "" {
+		if _, _, err := net.SplitHostPort(s.DNS.Server); err != nil {
+			s.DNS.Server = net.JoinHostPort(s.DNS.Server, "53")
+		}
+		log.Info().Msgf("HTTP client DNSServer: %v", s.DNS.Server)
+	}
+	if s.DNS.DoHURL != "" {
+		log.Info().Msgf("HTTP client DNSOverHTTPSURL: %v", s.DNS.DoHURL)
+	}
+	for _, entry := range viper.GetStringSlice("HTTPClient.resolve") {
+		hostPort, ips, err := parseResolveOverride(entry)
+		if err != nil {
+			panic(err)
+		}
+		if s.DNS.Overrides == nil {
+			s.DNS.Overrides = map[string][]net.IP{}
+		}
+		s.DNS.Overrides[hostPort] = ips
+		log.Info().Msgf("Resolving %v to %v", hostPort, ips)
+	}
+}
+
+// parseResolveOverride parses "host:port:address[,address]...", IPv6 addresses optionally in brackets
+func parseResolveOverride(entry string) (string, []net.IP, error) {
+	host, rest, _ := strings.Cut(strings.TrimSpace(entry), ":")
+	port, addresses, found := strings.Cut(rest, ":")
+	if _, err := strconv.ParseUint(port, 10, 16); host == "" || !found || err != nil {
+		return "", nil, fmt.Errorf("invalid resolve entry '%v', expected host:port:address", entry)
+	}
+	var ips []net.IP
+	for _, address := range strings.Split(addresses, ",") {
+		ip := net.ParseIP(strings.Trim(strings.TrimSpace(address), "[]"))
+		if ip == nil {
+			return "", nil, fmt.Errorf("invalid address '%v' in resolve entry '%v'", address, entry)
+		}
+		ips = append(ips, ip)
+	}
+	return net.JoinHostPort(strings.ToLower(host), port), ips, nil
+}
+
+// dnsResolver resolves host names via the system resolver, a DNS server or a DoH endpoint,
+// applying static overrides first. Lookups of a configured server are cached according to the record TTLs.
+type dnsResolver struct {
+	settings resolverSettings
+	system   *net.Resolver
+	cache    *cache.Cache
+	dialer   *net.Dialer
+	// dial connects to resolved addresses
+	dial dialContextFunc
+	// doh is the HTTP client used for DNS-over-HTTPS queries, itself using the system resolver
+	doh *http.Client
+}
+
+func newDNSResolver(settings resolverSettings) *dnsResolver {
+	r := &dnsResolver{
+		settings: settings,
+		system:   net.DefaultResolver,
+		cache:    cache.New(cache.NoExpiration, defaultCacheCleanupInterval),
+		// same defaults as the ones of the HTTP transport
+		dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		doh:    &http.Client{Timeout: defaultDNSTimeout},
+	}
+	r.dial = r.dialer.DialContext
+	return r
+}
+
+func (r *dnsResolver) custom() bool {
+	return r.settings.Server != "" || r.settings.DoHURL != ""
+}
+
+// DialContext connects to the address, resolving its host name via the resolver. Without a DNS server,
+// DoH endpoint or override for the address, the standard dialer resolves and connects.
+func (r *dnsResolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	_, overridden := r.settings.Overrides[net.JoinHostPort(strings.ToLower(host), port)]
+	if net.ParseIP(host) != nil || (!r.custom() && !overridden) {
+		return r.dial(ctx, network, address)
+	}
+	ips, err := r.lookupForDial(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	return r.dialParallel(ctx, network, port, ips)
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialParallel connects to the addresses of the first address family in turn, racing those of the other family
+// once the first attempt failed or dialFallbackDelay has passed, as the standard dialer does
+func (r *dnsResolver) dialParallel(ctx context.Context, network, port string, ips []net.IP) (net.Conn, error) {
+	var primaries, fallbacks []net.IP
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (ips[0].To4() != nil) {
+			primaries = append(primaries, ip)
+		} else {
+			fallbacks = append(fallbacks, ip)
+		}
+	}
+	if len(fallbacks) == 0 {
+		return r.dialSerial(ctx, network, port, primaries)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, 2)
+	race := func(ips []net.IP) {
+		conn, err := r.dialSerial(ctx, network, port, ips)
+		results <- dialResult{conn: conn, err: err}
+	}
+	go race(primaries)
+	fallbackTimer := time.NewTimer(dialFallbackDelay)
+	defer fallbackTimer.Stop()
+
+	pending, fallbackStarted := 1, false
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-fallbackTimer.C:
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// the losing connection, if any, is closed
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if lost := <-results; lost.conn != nil {
+							_ = lost.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+		}
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			go race(fallbacks)
+		}
+	}
+	return nil, firstErr
+}
+
+// dialSerial connects to the addresses in turn, each attempt limited to a share of the time left
+func (r *dnsResolver) dialSerial(ctx context.Context, network, port string, ips []net.IP) (net.Conn, error) {
+	var firstErr error
+	for i, ip := range ips {
+		attemptCtx, cancel := partialDeadline(ctx, len(ips)-i)
+		conn, err := r.dial(attemptCtx, network, net.JoinHostPort(ip.String(), port))
+		cancel()
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// partialDeadline shares the time left among the remaining addresses, with a minimum of minDialAttemptTimeout,
+// for a single unresponsive address not to use up the connect timeout
+func partialDeadline(ctx context.Context, remainingAddresses int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	timeout := time.Until(deadline) / time.Duration(remainingAddresses)
+	return context.WithTimeout(ctx, max(timeout, minDialAttemptTimeout))
+}
+
+// lookupForDial resolves the host, reporting the lookup to a client trace in the context, as the standard dialer does
+func (r *dnsResolver) lookupForDial(ctx context.Context, host, port string) ([]net.IP, error) {
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	lookupCtx, cancel := untracedContext(ctx)
+	defer cancel()
+	addrs, err := r.lookupIPAddr(lookupCtx, host, port)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: addrs, Err: err})
+	}
+	ips := make([]net.IP, 0, len(addrs))
+	for _, a := range addrs {
+		ips = append(ips, a.IP)
+	}
+	return ips, err
+}
+
+// untracedContext keeps the cancellation of ctx, but none of its values. Otherwise, connecting to the DNS server
+// would fire the connect hooks of the request's client trace, as the standard dialer prevents.
+func untracedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	var detached context.Context
+	var cancel context.CancelFunc
+	if deadline, ok := ctx.Deadline(); ok {
+		detached, cancel = context.WithDeadline(context.Background(), deadline)
+	} else {
+		detached, cancel = context.WithCancel(context.Background())
+	}
+	stop := context.AfterFunc(ctx, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
+
+func (r *dnsResolver) lookupIPAddr(ctx context.Context, host, port string) ([]net.IPAddr, error) {
+	if ips, ok := r.settings.Overrides[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		addrs := make([]net.IPAddr, 0, len(ips))
+		for _, ip := range ips {
+			addrs = append(addrs, net.IPAddr{IP: ip})
+		}
+		return addrs, nil
+	}
+	return r.LookupIPAddr(ctx, host)
+}
+
+// ResolveTCPAddr resolves a "host:port" address to the first address the host resolves to
+func (r *dnsResolver) ResolveTCPAddr(ctx context.Context, address string) (*net.TCPAddr, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	portNumber, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return &net.TCPAddr{IP: ip, Port: portNumber}, nil
+	}
+	addrs, err := r.lookupIPAddr(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	return &net.TCPAddr{IP: addrs[0].IP, Port: portNumber, Zone: addrs[0].Zone}, nil
+}
+
+// LookupIPAddr returns the IPv4 and IPv6 addresses of the host
+func (r *dnsResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	if !r.custom() {
+		return r.system.LookupIPAddr(ctx, host)
+	}
+	a, errA := r.query(ctx, host, dnsmessage.TypeA)
+	aaaa, errAAAA := r.query(ctx, host, dnsmessage.TypeAAAA)
+	var addrs []net.IPAddr
+	for _, resource := range append(a, aaaa...) {
+		switch body := resource.Body.(type) {
+		case *dnsmessage.AResource:
+			addrs = append(addrs, net.IPAddr{IP: net.IP(body.A[:])})
+		case *dnsmessage.AAAAResource:
+			addrs = append(addrs, net.IPAddr{IP: net.IP(body.AAAA[:])})
+		}
+	}
+	if len(addrs) > 0 {
+		return addrs, nil
+	}
+	if err := errors.Join(errA, errAAAA); err != nil {
+		return nil, err
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// LookupHost returns the addresses of the host as strings
+func (r *dnsResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, err := r.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	hosts := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		hosts = append(hosts, a.String())
+	}
+	return hosts, nil
+}
+
+// LookupMX returns the mail exchangers of the domain
+func (r *dnsResolver) LookupMX(ctx context.Context, domain string) ([]*net.MX, error) {
+	if !r.custom() {
+		return r.system.LookupMX(ctx, domain)
+	}
+	resources, err := r.query(ctx, domain, dnsmessage.TypeMX)
+	if err != nil {
+		return nil, err
+	}
+	var mx []*net.MX
+	for _, resource := range resources {
+		if body, ok := resource.Body.(*dnsmessage.MXResource); ok {
+			mx = append(mx, &net.MX{Host: body.MX.String(), Pref: body.Pref})
+		}
+	}
+	return mx, nil
+}
+
+// query returns the answers of the given type, cached for the lowest TTL among them
+func (r *dnsResolver) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	fqdn := strings.ToLower(name)
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	key := qtype.String() + " " + fqdn
+	if cached, found := r.cache.Get(key); found {
+		return cached.([]dnsmessage.Resource), nil
+	}
+
+	response, err := r.exchange(ctx, fqdn, qtype)
+	if err != nil {
+		return nil, err
+	}
+	var answers []dnsmessage.Resource
+	var ttl uint32
+	for _, resource := range response.Answers {
+		if resource.Header.Type != qtype {
+			// e.g. CNAME records of the chain leading to the answers
+			continue
+		}
+		if len(answers) == 0 || resource.Header.TTL < ttl {
+			ttl = resource.Header.TTL
+		}
+		answers = append(answers, resource)
+	}
+	if len(answers) > 0 && ttl > 0 {
+		r.cache.Set(key, answers, time.Duration(ttl)*time.Second)
+	}
+	return answers, nil
+}
+
+func (r *dnsResolver) exchange(ctx context.Context, fqdn string, qtype dnsmessage.Type) (*dnsmessage.Message, error) {
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, err
+	}
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	// RFC 8484 recommends the ID 0 for cache friendliness
+	if r.settings.DoHURL == "" {
+		query.Header.ID = uint16(rand.N(1 << 16))
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []byte
+	if r.settings.DoHURL != "" {
+		raw, err = r.exchangeDoH(ctx, packed)
+	} else {
+		raw, err = r.exchangeUDP(ctx, packed)
+	}
+	if err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: fqdn, Server: r.server()}
+	}
+
+	var response dnsmessage.Message
+	if err := response.Unpack(raw); err != nil {
+		return nil, &net.DNSError{Err: err.Error(), Name: fqdn, Server: r.server()}
+	}
+	if response.Header.Truncated && r.settings.DoHURL == "" {
+		if raw, err = r.exchangeTCP(ctx, packed); err == nil {
+			err = response.Unpack(raw)
+		}
+		if err != nil {
+			return nil, &net.DNSError{Err: err.Error(), Name: fqdn, Server: r.server()}
+		}
+	}
+	if response.Header.ID != query.Header.ID {
+		return nil, &net.DNSError{Err: "mismatched DNS response ID", Name: fqdn, Server: r.server()}
+	}
+	switch response.Header.RCode {
+	case dnsmessage.RCodeSuccess:
+		return &response, nil
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: fqdn, Server: r.server(), IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: "DNS server responded with " + response.Header.RCode.String(), Name: fqdn, Server: r.server()}
+	}
+}
+
+func (r *dnsResolver) server() string {
+	if r.settings.DoHURL != "" {
+		return r.settings.DoHURL
+	}
+	return r.settings.Server
+}
+
+func withDNSTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, defaultDNSTimeout)
+}
+
+func (r *dnsResolver) exchangeUDP(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := withDNSTimeout(ctx)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "udp", r.settings.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, maxDNSMessageSize)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return nil, err
+	}
+	return buffer[:n], nil
+}
+
+// exchangeTCP sends the query with the 2-byte length prefix of DNS over TCP
+func (r *dnsResolver) exchangeTCP(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := withDNSTimeout(ctx)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", r.settings.Server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(query)))); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	response := make([]byte, length)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func (r *dnsResolver) exchangeDoH(ctx context.Context, query []byte) ([]byte, error) {
+	ctx, cancel := withDNSTimeout(ctx)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.settings.DoHURL, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dnsMessageContentType)
+	req.Header.Set("Accept", dnsMessageContentType)
+	response, err := r.doh.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = response.Body.Close() }()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%v status from the DoH endpoint", response.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxDNSMessageSize))
+}
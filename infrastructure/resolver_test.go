@@ -0,0 +1,218 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	netUrl "net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// fakeDNSZone answers A queries of its hosts with 127.0.0.1, and NXDOMAIN for unknown hosts
+type fakeDNSZone struct {
+	hosts   map[string]bool
+	queries atomic.Int32
+}
+
+func (z *fakeDNSZone) answer(t *testing.T, raw []byte) []byte {
+	z.queries.Add(1)
+	var query dnsmessage.Message
+	require.NoError(t, query.Unpack(raw))
+	question := query.Questions[0]
+	response := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: query.Header.ID, Response: true, RecursionAvailable: true},
+		Questions: query.Questions,
+	}
+	switch {
+	case !z.hosts[question.Name.String()]:
+		response.Header.RCode = dnsmessage.RCodeNameError
+	case question.Type == dnsmessage.TypeA:
+		response.Answers = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+			Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+		}}
+	}
+	packed, err := response.Pack()
+	require.NoError(t, err)
+	return packed
+}
+
+func serveFakeDNS(t *testing.T, zone *fakeDNSZone) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buffer := make([]byte, maxDNSMessageSize)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(zone.answer(t, buffer[:n]), addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func serveFakeDoH(t *testing.T, zone *fakeDNSZone) string {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, dnsMessageContentType, r.Header.Get("Content-Type"))
+		query, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", dnsMessageContentType)
+		_, _ = w.Write(zone.answer(t, query))
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL + "/dns-query"
+}
+
+func portOf(t *testing.T, serverURL string) string {
+	u, err := netUrl.Parse(serverURL)
+	require.NoError(t, err)
+	return u.Port()
+}
+
+func TestCheckingURLsViaCustomDNSServer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	port := portOf(t, ts.URL)
+
+	for _, key := range []string{"HTTPClient.dnsServer", "HTTPClient.dnsOverHTTPSURL"} {
+		t.Run(key, func(t *testing.T) {
+			zone := &fakeDNSZone{hosts: map[string]bool{"staging.internal.test.": true}}
+			setUpViperTestConfiguration()
+			viper.Set("HTTPClient.enableRequestTracing", true)
+			if key == "HTTPClient.dnsServer" {
+				viper.Set(key, serveFakeDNS(t, zone))
+			} else {
+				viper.Set(key, serveFakeDoH(t, zone))
+			}
+			c := NewURLCheckerClient()
+
+			res := c.CheckURL(context.Background(), "http://staging.internal.test:"+port+"/")
+			assert.Equal(t, Ok, res.Status, "%v", res.Error)
+			assert.Equal(t, "127.0.0.1:"+port, res.RemoteAddr)
+
+			res = c.CheckURL(context.Background(), "http://unknown.internal.test:"+port+"/")
+			assert.Equal(t, Broken, res.Status)
+			assert.Contains(t, res.Error.Error(), "no such host")
+		})
+	}
+}
+
+func TestDNSLookupsAreCachedForTheirTTL(t *testing.T) {
+	zone := &fakeDNSZone{hosts: map[string]bool{"staging.internal.test.": true}}
+	r := newDNSResolver(resolverSettings{Server: serveFakeDNS(t, zone)})
+
+	for range 3 {
+		addrs, err := r.LookupIPAddr(context.Background(), "staging.internal.test")
+		require.NoError(t, err)
+		assert.Equal(t, []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1).To4()}}, addrs)
+	}
+	// the A answer is cached, while the empty AAAA answer has no TTL to be cached for
+	assert.Equal(t, int32(1+3), zone.queries.Load())
+}
+
+func TestStaticResolveOverrides(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	port := portOf(t, ts.URL)
+
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.resolve", []string{"Staging.Example.com:" + port + ":127.0.0.1"})
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), "http://staging.example.com:"+port+"/")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+}
+
+func TestParseResolveOverride(t *testing.T) {
+	hostPort, ips, err := parseResolveOverride("example.com:443:10.0.0.1,[::1]")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com:443", hostPort)
+	assert.Equal(t, []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("::1")}, ips)
+
+	for _, invalid := range []string{"example.com", "example.com:443", "example.com:https:10.0.0.1", "example.com:443:nope", ":443:10.0.0.1"} {
+		_, _, err := parseResolveOverride(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+// blackHoleDial connects to 192.0.2.1 via an in-memory pipe, while other addresses never respond
+func blackHoleDial(dialed *atomic.Int32) dialContextFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed.Add(1)
+		if host, _, _ := net.SplitHostPort(address); host == "192.0.2.1" {
+			client, server := net.Pipe()
+			_ = server.Close()
+			return client, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+}
+
+func TestAddressFamiliesAreRaced(t *testing.T) {
+	var dialed atomic.Int32
+	r := newDNSResolver(resolverSettings{Overrides: map[string][]net.IP{
+		"dual-stack.example.com:80": {net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2"), net.ParseIP("192.0.2.1")},
+	}})
+	r.dial = blackHoleDial(&dialed)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := r.DialContext(ctx, "tcp", "dual-stack.example.com:80")
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.Less(t, time.Since(start), time.Second, "the IPv4 address is tried after the fallback delay")
+}
+
+func TestUnresponsiveAddressesShareTheConnectTimeout(t *testing.T) {
+	var dialed atomic.Int32
+	r := newDNSResolver(resolverSettings{Overrides: map[string][]net.IP{
+		"multi.example.com:80": {net.ParseIP("192.0.2.99"), net.ParseIP("192.0.2.1")},
+	}})
+	r.dial = blackHoleDial(&dialed)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := r.DialContext(ctx, "tcp", "multi.example.com:80")
+	require.NoError(t, err, "the unresponsive address uses up half of the timeout only")
+	_ = conn.Close()
+	assert.Equal(t, int32(2), dialed.Load())
+}
+
+func TestTheStandardDialerIsUsedWithoutCustomResolution(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = l.Close() }()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+
+	var dialed atomic.Int32
+	r := newDNSResolver(resolverSettings{})
+	r.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed.Add(1)
+		assert.Equal(t, "localhost:"+port, address, "the host name is resolved by the standard dialer")
+		return r.dialer.DialContext(ctx, network, address)
+	}
+	conn, err := r.DialContext(context.Background(), "tcp", "localhost:"+port)
+	require.NoError(t, err)
+	_ = conn.Close()
+	assert.Equal(t, int32(1), dialed.Load())
+}
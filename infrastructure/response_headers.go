@@ -0,0 +1,65 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+func loadCapturedResponseHeadersFromViper(s *urlCheckerSettings) {
+	for _, name := range viper.GetStringSlice("captureResponseHeaders") {
+		if name = strings.TrimSpace(name); name != "" {
+			s.CaptureResponseHeaders = append(s.CaptureResponseHeaders, http.CanonicalHeaderKey(name))
+		}
+	}
+	if len(s.CaptureResponseHeaders) > 0 {
+		log.Info().Msgf("Will capture the response headers: %v", strings.Join(s.CaptureResponseHeaders, ", "))
+	}
+}
+
+// withResponseHeaders stores the allow-listed headers of the response the result is based on.
+// Multiple values of a header are joined with ", ".
+func (c *URLCheckerClient) withResponseHeaders(res *URLCheckResult, response *resty.Response) *URLCheckResult {
+	if len(c.settings.CaptureResponseHeaders) == 0 || response == nil || response.RawResponse == nil {
+		return res
+	}
+	header := response.Header()
+	for _, name := range c.settings.CaptureResponseHeaders {
+		values := header.Values(name)
+		// the Content-Length of a ranged GET is the length of the range, not of the resource
+		if name == "Content-Length" && response.StatusCode() == http.StatusPartialContent {
+			values = completeLengthOf(header.Get("Content-Range"))
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if res.Headers == nil {
+			res.Headers = map[string]string{}
+		}
+		res.Headers[name] = strings.Join(values, ", ")
+	}
+	return res
+}
+
+// completeLengthOf returns the complete length of a Content-Range header, e.g. 5000 of "bytes 0-1023/5000",
+// nil if it is unknown
+func completeLengthOf(contentRange string) []string {
+	_, length, found := strings.Cut(contentRange, "/")
+	if !found {
+		return nil
+	}
+	if _, err := strconv.ParseUint(length, 10, 64); err != nil {
+		return nil
+	}
+	return []string{length}
+}
@@ -0,0 +1,92 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapturingResponseHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Server", "test")
+		w.Header().Add("X-Robots-Tag", "noindex")
+		w.Header().Add("X-Robots-Tag", "nofollow")
+		if r.URL.Path == "/no-head" && r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("X-Method", r.Method)
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("captureResponseHeaders", []string{"content-type", "x-robots-tag", "ETag", "X-Method"})
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, map[string]string{
+		"Content-Type": "application/pdf",
+		"X-Robots-Tag": "noindex, nofollow",
+		"X-Method":     http.MethodHead,
+	}, res.Headers, "only the allow-listed headers present in the response")
+
+	res = c.CheckURL(context.Background(), ts.URL+"/no-head")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, http.MethodGet, res.Headers["X-Method"], "the headers of the response the result is based on")
+
+	viper.Set("captureResponseHeaders", nil)
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Nil(t, res.Headers)
+}
+
+func TestCapturedContentLengthOfRangedFallbacks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		if r.URL.Path == "/unknown-length" {
+			w.Header().Set("Content-Range", "bytes 0-1023/*")
+			w.WriteHeader(http.StatusPartialContent)
+			_, _ = w.Write(make([]byte, 1024))
+			return
+		}
+		http.ServeContent(w, r, "manual.pdf", time.Time{}, bytes.NewReader(make([]byte, 5000)))
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("captureResponseHeaders", []string{"Content-Length", "Content-Type"})
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL+"/manual.pdf")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, http.StatusPartialContent, res.Code)
+	assert.Equal(t, "5000", res.Headers["Content-Length"], "the length of the resource, not of the range")
+
+	res = c.CheckURL(context.Background(), ts.URL+"/unknown-length")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.NotContains(t, res.Headers, "Content-Length")
+	assert.Equal(t, "application/pdf", res.Headers["Content-Type"])
+}
+
+func TestCompleteLengthOfContentRanges(t *testing.T) {
+	assert.Equal(t, []string{"5000"}, completeLengthOf("bytes 0-1023/5000"))
+	assert.Nil(t, completeLengthOf("bytes 0-1023/*"))
+	assert.Nil(t, completeLengthOf(""))
+}
@@ -0,0 +1,133 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const defaultRetryBackoff = 1 * time.Second
+const defaultRetryMaxBackoff = 30 * time.Second
+
+// retryPolicy configures how often a check is repeated when a target is rate-limiting or temporarily unavailable
+type retryPolicy struct {
+	// Attempts is the number of additional attempts after the first one. No retries if 0.
+	Attempts   uint
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func loadRetryPolicyFromViper(s *urlCheckerSettings) {
+	s.Retries = retryPolicy{
+		Attempts:   viper.GetUint("HTTPClient.retryAttempts"),
+		Backoff:    defaultRetryBackoff,
+		MaxBackoff: defaultRetryMaxBackoff,
+	}
+	if s.Retries.Attempts == 0 {
+		return
+	}
+	s.Retries.Backoff = viperDuration("HTTPClient.retryBackoff", defaultRetryBackoff)
+	s.Retries.MaxBackoff = viperDuration("HTTPClient.retryMaxBackoff", defaultRetryMaxBackoff)
+	log.Info().Msgf("HTTP client RetryAttempts: %v", s.Retries.Attempts)
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// delayBefore returns the delay before the next attempt, and whether there should be one at all
+func (p retryPolicy) delayBefore(ctx context.Context, attempt int, res *URLCheckResult) (time.Duration, bool) {
+	if uint(attempt) >= p.Attempts || !isRetryableStatus(res.Code) {
+		return 0, false
+	}
+
+	// exponential backoff with jitter: a random delay in [backoff/2, backoff]
+	backoff := p.Backoff
+	for i := 0; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, p.MaxBackoff)
+	delay := backoff/2 + rand.N(backoff/2+1)
+
+	if res.retryAfter > 0 {
+		if res.retryAfter > p.MaxBackoff {
+			// the target asked for a longer pause than we are willing to wait for
+			return 0, false
+		}
+		delay = max(delay, res.retryAfter)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return 0, false
+	}
+	return delay, true
+}
+
+// retryAfterOf parses the Retry-After header given either in seconds or as an HTTP date
+func retryAfterOf(header http.Header) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+func sleepOrDone(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// checkWithRetries repeats the check according to the retry policy, tracing each attempt
+func (l *localURLChecker) checkWithRetries(ctx context.Context, urlToCheck string, client *resty.Client, proxy string) (*URLCheckResult, bool) {
+	policy := l.c.settings.Retries
+	var attempts []URLCheckerPluginTrace
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+		GlobalStats().OnOutgoingRequest()
+		res, shouldAbort := l.c.checkURL(ctx, urlToCheck, client)
+		res.proxy = proxy
+		GlobalStats().OnBytesTransferred(DomainOf(urlToCheck), res.BytesTransferred)
+		if policy.Attempts == 0 {
+			return res, shouldAbort
+		}
+
+		trace := checkerTraceEntry(l, res, time.Since(start))
+		trace.Attempt = attempt + 1
+		attempts = append(attempts, trace)
+		res.attempts = attempts
+
+		delay, retry := policy.delayBefore(ctx, attempt, res)
+		if shouldAbort || !retry {
+			return res, shouldAbort
+		}
+		log.Debug().Msgf("Retrying %v in %v after %v", sanitizeUserLogInput(urlToCheck), delay, res.Code)
+		if !sleepOrDone(ctx, delay) {
+			return res, shouldAbort
+		}
+	}
+}
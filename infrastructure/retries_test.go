@@ -0,0 +1,78 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryingFlakyTargets(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// HEAD and GET of the first attempt fail
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.retryAttempts", uint(2))
+	viper.Set("HTTPClient.retryBackoff", "10ms")
+	start := time.Now()
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, res.Status)
+	assert.GreaterOrEqual(t, time.Since(start), time.Second, "the Retry-After header should have been respected")
+	require.Len(t, res.CheckerTrace, 2)
+	assert.Equal(t, 1, res.CheckerTrace[0].Attempt)
+	assert.Equal(t, http.StatusServiceUnavailable, res.CheckerTrace[0].Code)
+	assert.Equal(t, 2, res.CheckerTrace[1].Attempt)
+	assert.Equal(t, http.StatusOK, res.CheckerTrace[1].Code)
+}
+
+func TestRetryPolicyDelays(t *testing.T) {
+	p := retryPolicy{Attempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	unavailable := &URLCheckResult{Code: http.StatusServiceUnavailable}
+
+	delay, retry := p.delayBefore(context.Background(), 2, unavailable)
+	assert.True(t, retry)
+	assert.GreaterOrEqual(t, delay, 200*time.Millisecond)
+	assert.LessOrEqual(t, delay, 400*time.Millisecond)
+
+	_, retry = p.delayBefore(context.Background(), 3, unavailable)
+	assert.False(t, retry, "the attempts are exhausted")
+
+	_, retry = p.delayBefore(context.Background(), 0, &URLCheckResult{Code: http.StatusNotFound})
+	assert.False(t, retry, "only 429 and 503 are retried")
+
+	_, retry = p.delayBefore(context.Background(), 0, &URLCheckResult{Code: http.StatusTooManyRequests, retryAfter: time.Minute})
+	assert.False(t, retry, "Retry-After beyond the max backoff should not be waited for")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, retry = p.delayBefore(ctx, 0, &URLCheckResult{Code: http.StatusTooManyRequests, retryAfter: 500 * time.Millisecond})
+	assert.False(t, retry, "the delay would exceed the deadline")
+}
+
+func TestParsingRetryAfter(t *testing.T) {
+	assert.Equal(t, 5*time.Second, retryAfterOf(http.Header{"Retry-After": {"5"}}))
+	assert.Equal(t, time.Duration(0), retryAfterOf(http.Header{}))
+	inAMinute := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	assert.InDelta(t, float64(time.Minute), float64(retryAfterOf(http.Header{"Retry-After": {inAMinute}})), float64(2*time.Second))
+}
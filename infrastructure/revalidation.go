@@ -0,0 +1,100 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// validators identify the version of a resource, for a later check to only transfer it if it changed
+type validators struct {
+	etag         string
+	lastModified string
+}
+
+// validatorsOf returns the ETag and Last-Modified validators of a response, nil if it has none
+func validatorsOf(response *resty.Response) *validators {
+	if response == nil {
+		return nil
+	}
+	v := &validators{
+		etag:         response.Header().Get("ETag"),
+		lastModified: response.Header().Get("Last-Modified"),
+	}
+	if v.etag == "" && v.lastModified == "" {
+		return nil
+	}
+	return v
+}
+
+type revalidationKey struct{}
+
+// withRevalidation makes the requests of a check conditional on the resource having changed since the cached result
+func withRevalidation(ctx context.Context, cached *URLCheckResult) context.Context {
+	return context.WithValue(ctx, revalidationKey{}, cached.validators)
+}
+
+func validatorsToRevalidate(ctx context.Context) *validators {
+	v, _ := ctx.Value(revalidationKey{}).(*validators)
+	return v
+}
+
+// applyValidatorsTo sends the validators of the cached result being revalidated, if any
+func applyValidatorsTo(ctx context.Context, req *resty.Request) *resty.Request {
+	v := validatorsToRevalidate(ctx)
+	if v == nil {
+		return req
+	}
+	if v.etag != "" {
+		req.SetHeader("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.SetHeader("If-Modified-Since", v.lastModified)
+	}
+	return req
+}
+
+// notModifiedResult returns an ok result marked as not modified for a 304 response to a revalidation, nil otherwise
+func notModifiedResult(response *resty.Response, nowEpoch int64) *URLCheckResult {
+	if response.StatusCode() != http.StatusNotModified || response.Request == nil ||
+		validatorsToRevalidate(response.Request.Context()) == nil {
+		return nil
+	}
+	return &URLCheckResult{
+		Status:                Ok,
+		Code:                  http.StatusNotModified,
+		FetchedAtEpochSeconds: nowEpoch,
+		BodyPatternsFound:     []string{},
+		validators:            validatorsOf(response),
+		notModified:           true,
+	}
+}
+
+// canBeRevalidated is true for ok results with validators
+func (res *URLCheckResult) canBeRevalidated() bool {
+	return res.Status == Ok && res.validators != nil
+}
+
+// revalidatedBy refreshes a cached result confirmed to be unchanged by a not modified result.
+// The results of the previous check, e.g. the body patterns found, carry over.
+func (res *URLCheckResult) revalidatedBy(notModified *URLCheckResult) *URLCheckResult {
+	refreshed := *res
+	refreshed.FetchedAtEpochSeconds = notModified.FetchedAtEpochSeconds
+	refreshed.RemoteAddr = notModified.RemoteAddr
+	refreshed.CheckerTrace = notModified.CheckerTrace
+	refreshed.ElapsedMs = notModified.ElapsedMs
+	refreshed.BytesTransferred = notModified.BytesTransferred
+	refreshed.Timing = notModified.Timing
+	refreshed.Revalidated = true
+	if notModified.validators != nil {
+		refreshed.validators = notModified.validators
+	}
+	return &refreshed
+}
@@ -0,0 +1,105 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// revalidationTestServer serves /stable with a fixed ETag, and /changing with a new ETag on each request.
+// It counts the conditional requests, and the bodies sent.
+func revalidationTestServer(t *testing.T, conditional, bodies *atomic.Int64) *httptest.Server {
+	var version atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"v1"`
+		if r.URL.Path == "/changing" {
+			etag = fmt.Sprintf(`"v%d"`, version.Add(1)+1)
+		}
+		if r.Header.Get("If-None-Match") != "" {
+			conditional.Add(1)
+		}
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Method == http.MethodGet {
+			bodies.Add(1)
+		}
+		_, _ = fmt.Fprint(w, "<html>the documentation</html>")
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestExpiredResultsAreRevalidated(t *testing.T) {
+	var conditional, bodies atomic.Int64
+	ts := revalidationTestServer(t, &conditional, &bodies)
+
+	setUpViperTestConfiguration()
+	viper.Set("cacheExpirationInterval", "1s")
+	viper.Set("cacheRevalidationWindow", "1h")
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{{Name: "docs", Regex: "documentation"}})
+	t.Cleanup(func() { viper.Set("bodyPatterns", nil) })
+	ResetGlobalStats()
+	c := NewCachedURLChecker()
+
+	first := c.CheckURL(context.Background(), ts.URL+"/stable")
+	assert.Equal(t, Ok, first.Status, "%v", first.Error)
+	assert.Equal(t, []string{"docs"}, first.BodyPatternsFound)
+	assert.Same(t, first, c.CheckURL(context.Background(), ts.URL+"/stable"), "not expired yet")
+	assert.Equal(t, int64(0), conditional.Load())
+
+	time.Sleep(2100 * time.Millisecond)
+	res := c.CheckURL(context.Background(), ts.URL+"/stable")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.True(t, res.Revalidated)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, []string{"docs"}, res.BodyPatternsFound, "the body patterns found carry over")
+	assert.Greater(t, res.FetchedAtEpochSeconds, first.FetchedAtEpochSeconds)
+	assert.Equal(t, int64(1), conditional.Load())
+	assert.Equal(t, int64(1), bodies.Load(), "the body is not transferred again")
+	assert.Equal(t, int64(1), GlobalStats().GetStats().CacheRevalidations)
+	assert.False(t, first.Revalidated, "the cached result is not modified")
+	assert.Same(t, res, c.CheckURL(context.Background(), ts.URL+"/stable"), "the revalidated result is cached")
+
+	first = c.CheckURL(context.Background(), ts.URL+"/changing")
+	time.Sleep(2100 * time.Millisecond)
+	res = c.CheckURL(context.Background(), ts.URL+"/changing")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.False(t, res.Revalidated)
+	assert.Equal(t, []string{"docs"}, res.BodyPatternsFound)
+	assert.Greater(t, res.FetchedAtEpochSeconds, first.FetchedAtEpochSeconds)
+	assert.Equal(t, int64(3), bodies.Load(), "a changed resource is fetched in full")
+}
+
+func TestNoConditionalRequestsWithoutRevalidationWindow(t *testing.T) {
+	var conditional, bodies atomic.Int64
+	ts := revalidationTestServer(t, &conditional, &bodies)
+
+	setUpViperTestConfiguration()
+	viper.Set("cacheExpirationInterval", "1s")
+	c := NewCachedURLChecker()
+
+	first := c.CheckURL(context.Background(), ts.URL+"/stable")
+	assert.Equal(t, Ok, first.Status, "%v", first.Error)
+	time.Sleep(2100 * time.Millisecond)
+	res := c.CheckURL(context.Background(), ts.URL+"/stable")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.False(t, res.Revalidated)
+	assert.Equal(t, int64(0), conditional.Load())
+}
@@ -0,0 +1,289 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	netUrl "net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gobwas/glob"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const skipReasonRobotsDisallowed = "robots_disallowed"
+const skipReasonRobotsUnreachable = "robots_unreachable"
+
+// robotsTxtRetryInterval is the delay before fetching the robots.txt of a host that did not respond again
+const robotsTxtRetryInterval = 10 * time.Minute
+
+// errRobotsTxtServerError marks a robots.txt the server responded to with a 5xx status
+var errRobotsTxtServerError = errors.New("server error")
+
+// robotsTxtMaxBytes is the size limit of a robots.txt file, as recommended by RFC 9309
+const robotsTxtMaxBytes = 500 * 1024
+
+func loadRobotsTxtFromViper(s *urlCheckerSettings) {
+	s.RespectRobotsTxt = viper.GetBool("respectRobotsTxt")
+	if !s.RespectRobotsTxt {
+		return
+	}
+	for _, domain := range viper.GetStringSlice("robotsTxtExemptDomains") {
+		g, err := glob.Compile(domain)
+		if err != nil {
+			panic(fmt.Errorf("could not parse the robots.txt exemption '%v': %v", domain, err.Error()))
+		}
+		s.RobotsTxtExemptDomains = append(s.RobotsTxtExemptDomains, g)
+	}
+	log.Info().Msgf("Will respect robots.txt, except for %v exempt domain globs", len(s.RobotsTxtExemptDomains))
+}
+
+// robotsTxt holds the rules of the groups matching the user agent
+type robotsTxt struct {
+	rules      []robotsTxtRule
+	crawlDelay time.Duration
+}
+
+type robotsTxtRule struct {
+	allow bool
+	// length of the path pattern, the longest matching pattern wins
+	length  int
+	pattern *regexp.Regexp
+}
+
+type robotsTxtGroup struct {
+	agents     []string
+	rules      []robotsTxtRule
+	crawlDelay time.Duration
+}
+
+// allows is true if the longest matching rule allows the path, or if no rule matches. Allow wins ties.
+func (r *robotsTxt) allows(path string) bool {
+	if r == nil || path == "/robots.txt" {
+		return true
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > longest || (rule.length == longest && rule.allow) {
+			allowed, longest = rule.allow, rule.length
+		}
+	}
+	return allowed
+}
+
+// productTokenOf returns the name robots.txt groups are matched against, e.g. "lcs" for "lcs/0.9"
+func productTokenOf(userAgent string) string {
+	token, _, _ := strings.Cut(strings.TrimSpace(userAgent), "/")
+	token, _, _ = strings.Cut(token, " ")
+	return strings.ToLower(token)
+}
+
+// parseRobotsTxt keeps the groups matching the product token of the user agent, or the "*" groups otherwise
+func parseRobotsTxt(body io.Reader, userAgent string) *robotsTxt {
+	var groups []*robotsTxtGroup
+	var current *robotsTxtGroup
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key == "user-agent" {
+			// a user agent line following rules starts a new group
+			if current == nil || len(current.rules) > 0 || current.crawlDelay > 0 {
+				current = &robotsTxtGroup{}
+				groups = append(groups, current)
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		switch key {
+		case "allow", "disallow":
+			if value == "" {
+				continue
+			}
+			current.rules = append(current.rules, robotsTxtRule{
+				allow:   key == "allow",
+				length:  len(value),
+				pattern: robotsTxtPatternOf(value),
+			})
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				current.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	token := productTokenOf(userAgent)
+	res := &robotsTxt{}
+	for _, agent := range []string{token, "*"} {
+		for _, group := range groups {
+			if !slices.Contains(group.agents, agent) {
+				continue
+			}
+			res.rules = append(res.rules, group.rules...)
+			res.crawlDelay = max(res.crawlDelay, group.crawlDelay)
+		}
+		if len(res.rules) > 0 || res.crawlDelay > 0 {
+			break
+		}
+	}
+	return res
+}
+
+// robotsTxtPatternOf matches path prefixes, with "*" matching any sequence and a trailing "$" the end of the path
+func robotsTxtPatternOf(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+	var b strings.Builder
+	b.WriteString("^")
+	for i, part := range strings.Split(path, "*") {
+		if i > 0 {
+			b.WriteString(".*")
+		}
+		b.WriteString(regexp.QuoteMeta(part))
+	}
+	if anchored {
+		b.WriteString("$")
+	}
+	return regexp.MustCompile(b.String())
+}
+
+// robotsTxtLocationOf returns the origin the rules apply to, the robots.txt URL, and the path to match
+func robotsTxtLocationOf(urlToCheck string) (string, string, string, bool) {
+	u, err := netUrl.Parse(urlToCheck)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", "", false
+	}
+	origin := u.Scheme + "://" + u.Host
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return origin, origin + "/robots.txt", path, true
+}
+
+func (s urlCheckerSettings) isExemptFromRobotsTxt(urlToCheck string) bool {
+	domain := DomainOf(urlToCheck)
+	for _, g := range s.RobotsTxtExemptDomains {
+		if g.Match(domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// robotsTxtDisallowed returns a skipped result if robots.txt disallows checking the URL, nil otherwise.
+// A robots.txt the server fails to respond with (5xx) disallows everything (RFC 9309, 2.3.1.4), and is fetched again
+// by the next check of the origin. If the host does not respond at all, the checks proceed to report the failure,
+// without fetching robots.txt again for robotsTxtRetryInterval.
+func (c *URLCheckerClient) robotsTxtDisallowed(ctx context.Context, urlToCheck string, client *resty.Client) *URLCheckResult {
+	if !c.settings.RespectRobotsTxt || c.settings.isExemptFromRobotsTxt(urlToCheck) {
+		return nil
+	}
+	origin, robotsURL, path, ok := robotsTxtLocationOf(urlToCheck)
+	if !ok {
+		return nil
+	}
+	robots, err := c.robotsTxts.get(origin, func() (*robotsTxt, error) {
+		// the fetch is shared by the concurrent checks of the origin, thus not canceled with the check starting it
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.robotsTxtFetchTimeout())
+		defer cancel()
+		return c.fetchRobotsTxt(fetchCtx, robotsURL, client)
+	})
+	switch {
+	case errors.Is(err, errRobotsTxtServerError):
+		log.Debug().Err(err).Msgf("Could not fetch %v", sanitizeUserLogInput(robotsURL))
+		return robotsTxtSkippedResult(urlToCheck, skipReasonRobotsUnreachable,
+			fmt.Errorf("'%v' is disallowed, as %v is unreachable: %w", urlToCheck, robotsURL, err))
+	case err != nil:
+		log.Debug().Err(err).Msgf("Could not fetch %v", sanitizeUserLogInput(robotsURL))
+		c.robotsTxts.set(origin, &robotsTxt{}, robotsTxtRetryInterval)
+		return nil
+	case robots.allows(path):
+		return nil
+	default:
+		return robotsTxtSkippedResult(urlToCheck, skipReasonRobotsDisallowed,
+			fmt.Errorf("'%v' is disallowed by %v", urlToCheck, robotsURL))
+	}
+}
+
+func robotsTxtSkippedResult(urlToCheck, skipReason string, err error) *URLCheckResult {
+	GlobalStats().OnLinkSkipped(DomainOf(urlToCheck))
+	return &URLCheckResult{
+		Status:                Skipped,
+		SkipReason:            skipReason,
+		Error:                 err,
+		FetchedAtEpochSeconds: time.Now().Unix(),
+		BodyPatternsFound:     []string{},
+	}
+}
+
+func (c *URLCheckerClient) robotsTxtFetchTimeout() time.Duration {
+	if c.settings.TimeoutSeconds == 0 {
+		return defaultTimeoutSeconds * time.Second
+	}
+	return time.Duration(c.settings.TimeoutSeconds) * time.Second
+}
+
+// fetchRobotsTxt returns the parsed rules, or no rules if robots.txt is unavailable (4xx, or too many redirects).
+// Returns an error wrapping errRobotsTxtServerError for 5xx statuses, or the error of a request without a response.
+func (c *URLCheckerClient) fetchRobotsTxt(ctx context.Context, robotsURL string, client *resty.Client) (*robotsTxt, error) {
+	response, err := c.newRequest(ctx, client, robotsURL, c.settings.UserAgent).
+		SetDoNotParseResponse(true).
+		Get(robotsURL)
+	if err != nil {
+		return nil, err
+	}
+	body := c.bodyOf(response)
+	defer func() { _ = body.Close() }()
+	switch code := response.StatusCode(); {
+	case code >= 500:
+		return nil, fmt.Errorf("%w: %v status on url '%v'", errRobotsTxtServerError, code, robotsURL)
+	case code >= 300:
+		// 3xx: the redirect limit was hit
+		return &robotsTxt{}, nil
+	}
+	return parseRobotsTxt(io.LimitReader(body, robotsTxtMaxBytes), c.settings.UserAgent), nil
+}
+
+// crawlDelayOf returns the Crawl-delay of the robots.txt already fetched for the URL's origin, 0 if unknown
+func (c *URLCheckerClient) crawlDelayOf(urlToCheck string) time.Duration {
+	if !c.settings.RespectRobotsTxt || c.settings.isExemptFromRobotsTxt(urlToCheck) {
+		return 0
+	}
+	origin, _, _, ok := robotsTxtLocationOf(urlToCheck)
+	if !ok {
+		return 0
+	}
+	if robots, found := c.robotsTxts.cached(origin); found {
+		return robots.crawlDelay
+	}
+	return 0
+}
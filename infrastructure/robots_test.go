@@ -0,0 +1,202 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+const sampleRobotsTxt = `
+# comments are ignored
+User-agent: *
+Disallow: /
+
+User-agent: other-bot
+User-agent: LCS
+Disallow: /private      # trailing comment
+Allow: /private/public
+Disallow: /*.pdf$
+Disallow:
+Crawl-delay: 0.2
+`
+
+func TestParsingRobotsTxt(t *testing.T) {
+	robots := parseRobotsTxt(strings.NewReader(sampleRobotsTxt), "lcs/0.9")
+	tests := []struct {
+		path    string
+		allowed bool
+	}{
+		{"/", true},
+		{"/robots.txt", true},
+		{"/private", false},
+		{"/private/secret", false},
+		{"/private/public/page", true},
+		{"/docs/manual.pdf", false},
+		{"/docs/manual.pdf?download=1", true},
+		{"/publications", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, robots.allows(tt.path), tt.path)
+	}
+	assert.Equal(t, 200*time.Millisecond, robots.crawlDelay)
+
+	others := parseRobotsTxt(strings.NewReader(sampleRobotsTxt), "Mozilla/5.0 (X11)")
+	assert.False(t, others.allows("/anything"), "the * group applies to other agents")
+	assert.Zero(t, others.crawlDelay)
+
+	assert.True(t, parseRobotsTxt(strings.NewReader(""), "lcs/0.9").allows("/private"))
+}
+
+func robotsTxtServer(t *testing.T, robotsTxt string, fetches *atomic.Int64) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			return
+		}
+		fetches.Add(1)
+		if r.UserAgent() != "lcs/0.9" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if robotsTxt == "" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(robotsTxt))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestRobotsTxtDisallowedURLsAreSkipped(t *testing.T) {
+	var fetches atomic.Int64
+	ts := robotsTxtServer(t, sampleRobotsTxt, &fetches)
+
+	setUpViperTestConfiguration()
+	viper.Set("respectRobotsTxt", true)
+	c := NewURLCheckerClient()
+
+	ResetGlobalStats()
+	res := c.CheckURL(context.Background(), ts.URL+"/private/secret")
+	assert.Equal(t, Skipped, res.Status)
+	assert.Equal(t, skipReasonRobotsDisallowed, res.SkipReason)
+	assert.Equal(t, int64(1), GlobalStats().GetStats().LinkChecksSkipped)
+
+	res = c.CheckURL(context.Background(), ts.URL+"/private/public/page")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, int64(1), fetches.Load(), "robots.txt is cached per host")
+
+	viper.Set("robotsTxtExemptDomains", []string{"127.0.0.*"})
+	res = NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/private/secret")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, int64(1), fetches.Load(), "robots.txt is not fetched for exempt domains")
+}
+
+func TestMissingRobotsTxtAllowsEverything(t *testing.T) {
+	var fetches atomic.Int64
+	ts := robotsTxtServer(t, "", &fetches)
+
+	setUpViperTestConfiguration()
+	viper.Set("respectRobotsTxt", true)
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/private")
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, int64(1), fetches.Load())
+}
+
+func TestUnreachableRobotsTxtDisallowsEverything(t *testing.T) {
+	var pageRequests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		pageRequests.Add(1)
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("respectRobotsTxt", true)
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/page")
+	assert.Equal(t, Skipped, res.Status)
+	assert.Equal(t, skipReasonRobotsUnreachable, res.SkipReason)
+	assert.Contains(t, res.Error.Error(), "503")
+	assert.Zero(t, pageRequests.Load())
+}
+
+func TestURLsOfUnresolvableHostsAreBrokenDespiteRobotsTxt(t *testing.T) {
+	setUpViperTestConfiguration()
+	viper.Set("respectRobotsTxt", true)
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), "http://lcs-robots-test.invalid/page")
+	assert.Equal(t, Broken, res.Status, "%v", res.Error)
+	assert.Empty(t, res.SkipReason)
+	assert.Equal(t, ErrorCategoryDNSNotFound, res.ErrorCategory)
+	_, cached := c.robotsTxts.cached("http://lcs-robots-test.invalid")
+	assert.True(t, cached, "robots.txt is not fetched again for the next URL of the host")
+}
+
+func TestSharedRobotsTxtFetchesOutliveTheCheckStartingThem(t *testing.T) {
+	fetching := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fetching <- struct{}{}
+			time.Sleep(300 * time.Millisecond)
+			_, _ = w.Write([]byte(sampleRobotsTxt))
+		}
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("respectRobotsTxt", true)
+	c := NewURLCheckerClient()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go c.CheckURL(ctx, ts.URL+"/first")
+	<-fetching
+	second := make(chan *URLCheckResult)
+	go func() { second <- c.CheckURL(context.Background(), ts.URL+"/second") }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	res := <-second
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+}
+
+func TestCrawlDelayFeedsTheDomainRateLimiter(t *testing.T) {
+	var fetches atomic.Int64
+	ts := robotsTxtServer(t, sampleRobotsTxt, &fetches)
+
+	setUpViperTestConfiguration()
+	viper.Set("respectRobotsTxt", true)
+	c := NewDomainRateLimitedChecker(0)
+	require.Equal(t, rate.Limit(0), c.limitFor(ts.URL), "the crawl delay is not known yet")
+
+	res := c.CheckURL(context.Background(), ts.URL)
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, rate.Every(200*time.Millisecond), c.limitFor(ts.URL))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		res = c.CheckURL(context.Background(), ts.URL)
+		assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+
+	c.ratePerSecond = 2
+	assert.Equal(t, rate.Limit(2), c.limitFor(ts.URL), "the configured rate is stricter")
+}
@@ -0,0 +1,191 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	netUrl "net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const skipReasonUnsupportedScheme = "unsupported_scheme"
+
+// schemeHandler checks URLs of a non-HTTP scheme in place of the checker plugin chain
+type schemeHandler interface {
+	check(ctx context.Context, u *netUrl.URL) error
+}
+
+// httpSchemes are handled by the checker plugin chain
+var httpSchemes = map[string]bool{
+	"":      true, // left to the HTTP client to report
+	"http":  true,
+	"https": true,
+	"ws":    true,
+	"wss":   true,
+}
+
+func newSchemeHandlers(c *URLCheckerClient) map[string]schemeHandler {
+	timeout := time.Duration(c.settings.TimeoutSeconds) * time.Second
+	return map[string]schemeHandler{
+		"mailto": &mailtoHandler{c: c},
+		"tel":    telHandler{},
+		"data":   dataHandler{},
+		"ftp":    &ftpHandler{c: c, timeout: timeout},
+	}
+}
+
+// checkNonHTTPScheme dispatches URLs of non-HTTP schemes to their handlers. Returns nil for HTTP URLs.
+func (c *URLCheckerClient) checkNonHTTPScheme(ctx context.Context, urlToCheck string) *URLCheckResult {
+	u, err := netUrl.Parse(urlToCheck)
+	if err != nil {
+		return nil
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if httpSchemes[scheme] {
+		return nil
+	}
+
+	start := time.Now()
+	domain := schemeDomainOf(u)
+	res := &URLCheckResult{
+		Status:                Ok,
+		FetchedAtEpochSeconds: start.Unix(),
+		BodyPatternsFound:     []string{},
+	}
+
+	handler, ok := c.schemeHandlers[scheme]
+	if !ok {
+		res.Status = Skipped
+		res.SkipReason = skipReasonUnsupportedScheme
+		res.Error = fmt.Errorf("the '%v' scheme is not supported", scheme)
+		GlobalStats().OnLinkSkipped(domain)
+	} else if err := handler.check(ctx, u); err != nil {
+		res.Status = Broken
+		res.Error = err
+		GlobalStats().OnLinkBroken(domain, scheme)
+	} else {
+		GlobalStats().OnLinkOk(domain)
+	}
+
+	elapsed := time.Since(start)
+	res.ElapsedMs = int64(elapsed / time.Millisecond)
+	res.CheckerTrace = []URLCheckerPluginTrace{{
+		Name:      scheme,
+		ElapsedMs: res.ElapsedMs,
+		Error:     errorMessageOf(res.Error),
+	}}
+	return res
+}
+
+func errorMessageOf(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// schemeDomainOf returns the domain to track in the stats for non-HTTP URLs
+func schemeDomainOf(u *netUrl.URL) string {
+	if u.Hostname() != "" {
+		return u.Hostname()
+	}
+	if strings.EqualFold(u.Scheme, "mailto") {
+		if addresses, err := mailtoAddressesOf(u); err == nil && len(addresses) > 0 {
+			return domainOfAddress(addresses[0])
+		}
+	}
+	return strings.ToLower(u.Scheme) + ":"
+}
+
+type mailtoHandler struct {
+	c *URLCheckerClient
+}
+
+func mailtoAddressesOf(u *netUrl.URL) ([]string, error) {
+	to, err := netUrl.PathUnescape(u.Opaque)
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, a := range strings.Split(to, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addresses = append(addresses, a)
+		}
+	}
+	return addresses, nil
+}
+
+func domainOfAddress(address string) string {
+	return strings.ToLower(address[strings.LastIndex(address, "@")+1:])
+}
+
+// check validates the address syntax and looks up the mail exchangers of the domain,
+// falling back to the domain's address records (implicit MX)
+func (h *mailtoHandler) check(ctx context.Context, u *netUrl.URL) error {
+	addresses, err := mailtoAddressesOf(u)
+	if err != nil {
+		return fmt.Errorf("invalid mailto URL: %w", err)
+	}
+	for _, address := range addresses {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil {
+			return fmt.Errorf("invalid email address '%v': %w", address, err)
+		}
+		domain := domainOfAddress(parsed.Address)
+		mx, mxErr := h.c.resolver.LookupMX(ctx, domain)
+		if mxErr == nil && len(mx) > 0 {
+			continue
+		}
+		if _, err := h.c.resolver.LookupHost(ctx, domain); err != nil {
+			return fmt.Errorf("no mail exchanger found for '%v': %w", domain, err)
+		}
+	}
+	return nil
+}
+
+// see https://www.rfc-editor.org/rfc/rfc3966 for the number syntax, leniently allowing spaces
+var telNumberPattern = regexp.MustCompile(`^\+?[0-9().\- ]*[0-9][0-9().\- ]*(;[^;]+)*$`)
+
+type telHandler struct{}
+
+func (telHandler) check(_ context.Context, u *netUrl.URL) error {
+	number, err := netUrl.PathUnescape(u.Opaque)
+	if err != nil || !telNumberPattern.MatchString(number) {
+		return fmt.Errorf("invalid telephone number '%v'", u.Opaque)
+	}
+	return nil
+}
+
+type dataHandler struct{}
+
+// check validates the data URL syntax: data:[<mediatype>][;base64],<data>
+func (dataHandler) check(_ context.Context, u *netUrl.URL) error {
+	header, data, found := strings.Cut(u.Opaque, ",")
+	if !found {
+		return fmt.Errorf("invalid data URL: missing ','")
+	}
+	if !strings.HasSuffix(strings.ToLower(header), ";base64") {
+		if _, err := netUrl.PathUnescape(data); err != nil {
+			return fmt.Errorf("invalid data URL: %w", err)
+		}
+		return nil
+	}
+	data, err := netUrl.PathUnescape(data)
+	if err == nil {
+		// tolerate missing padding
+		_, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "="))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid base64 data URL: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// sharedFetchCache caches the results of fetches by key, and runs only one fetch per key
+// for all concurrent callers
+type sharedFetchCache[T any] struct {
+	cache *cache.Cache
+
+	mu       sync.Mutex
+	inFlight map[string]*sharedFetch[T]
+}
+
+type sharedFetch[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newSharedFetchCache[T any](expiration time.Duration) *sharedFetchCache[T] {
+	return &sharedFetchCache[T]{
+		cache:    cache.New(expiration, defaultCacheCleanupInterval),
+		inFlight: map[string]*sharedFetch[T]{},
+	}
+}
+
+// cached returns the cached value without fetching it
+func (a *sharedFetchCache[T]) cached(key string) (T, bool) {
+	if value, found := a.cache.Get(key); found {
+		return value.(T), true
+	}
+	var zero T
+	return zero, false
+}
+
+// set caches a value for the given duration instead of the default expiration
+func (a *sharedFetchCache[T]) set(key string, value T, expiration time.Duration) {
+	a.cache.Set(key, value, expiration)
+}
+
+// get returns the cached value, or fetches it once. Failed fetches are not cached.
+func (a *sharedFetchCache[T]) get(key string, fetch func() (T, error)) (T, error) {
+	if value, found := a.cache.Get(key); found {
+		return value.(T), nil
+	}
+
+	a.mu.Lock()
+	if f, ok := a.inFlight[key]; ok {
+		a.mu.Unlock()
+		<-f.done
+		return f.value, f.err
+	}
+	f := &sharedFetch[T]{done: make(chan struct{})}
+	a.inFlight[key] = f
+	a.mu.Unlock()
+
+	f.value, f.err = fetch()
+	if f.err == nil {
+		a.cache.SetDefault(key, f.value)
+	}
+
+	a.mu.Lock()
+	delete(a.inFlight, key)
+	a.mu.Unlock()
+	close(f.done)
+
+	return f.value, f.err
+}
@@ -0,0 +1,239 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/bits"
+	"net/http"
+	netUrl "net/url"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/html"
+)
+
+const soft404MaxBodyBytes = 1 << 20
+const soft404MaxSimhashDistance = 8
+const soft404MaxSizeDeviation = 0.2
+const soft404ShingleSize = 3
+
+// pageFingerprint summarizes a response body to compare pages for similarity
+type pageFingerprint struct {
+	size    int
+	title   string
+	simhash uint64
+	// finalURL is the URL the page was served from after redirects, if fetched
+	finalURL string
+}
+
+// soft404ProbeURLOf returns the URL of a path that is not expected to exist on the host of the URL
+func soft404ProbeURLOf(urlToCheck string) (string, string, bool) {
+	u, err := netUrl.Parse(urlToCheck)
+	if err != nil || u.Host == "" {
+		return "", "", false
+	}
+	host := u.Scheme + "://" + u.Host
+	return host, host + "/lcs-soft-404-probe-" + uuid.NewString(), true
+}
+
+func (c *URLCheckerClient) detectSoft404(ctx context.Context, urlToCheck string, client *resty.Client, res *URLCheckResult) *URLCheckResult {
+	if !c.settings.DetectSoft404 || res.Status != Ok || res.Code != http.StatusOK {
+		return res
+	}
+	host, probeURL, ok := soft404ProbeURLOf(urlToCheck)
+	if !ok {
+		return res
+	}
+
+	probe, err := c.soft404Probes.get(host, func() (*pageFingerprint, error) {
+		return c.fetchFingerprint(ctx, probeURL, client)
+	})
+	if err != nil {
+		log.Debug().Err(err).Msgf("Could not probe %v for soft 404s", sanitizeUserLogInput(host))
+		return res
+	}
+	// the host responds to non-existent pages properly
+	if probe == nil {
+		return res
+	}
+
+	// hosts redirecting non-existent paths, e.g. to the home page, serve the probe from the checked URL itself
+	if sameURL(probe.finalURL, urlToCheck) {
+		return res
+	}
+
+	// the body of the GET request of the check, if any, is not fetched again
+	page := res.fingerprint
+	if page == nil {
+		page, err = c.fetchFingerprint(ctx, urlToCheck, client)
+	}
+	if err != nil || page == nil || !page.resembles(probe) {
+		return res
+	}
+
+	result := *res
+	result.Status = Soft404
+	result.Error = fmt.Errorf("'%v' resembles the page returned for non-existent paths", urlToCheck)
+	return &result
+}
+
+// fetchFingerprint returns the fingerprint of a successful response, or nil for any other status
+func (c *URLCheckerClient) fetchFingerprint(ctx context.Context, urlToCheck string, client *resty.Client) (*pageFingerprint, error) {
+	response, err := c.newRequest(ctx, client, urlToCheck, c.settings.BrowserUserAgent).
+		SetDoNotParseResponse(true).
+		Get(urlToCheck)
+	if err != nil {
+		return nil, err
+	}
+	body := c.bodyOf(response)
+	if body == nil {
+		return nil, fmt.Errorf("no response body")
+	}
+	defer func() { _ = body.Close() }()
+
+	if response.StatusCode() != http.StatusOK {
+		return nil, nil
+	}
+	fingerprint := fingerprintOf(readAllSafe(io.LimitReader(c.limitedReader(body), soft404MaxBodyBytes)))
+	if response.RawResponse != nil && response.RawResponse.Request != nil {
+		fingerprint.finalURL = response.RawResponse.Request.URL.String()
+	}
+	return fingerprint, nil
+}
+
+// sameURL compares URLs ignoring the difference between an empty path and "/"
+func sameURL(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ua, errA := netUrl.Parse(a)
+	ub, errB := netUrl.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	for _, u := range []*netUrl.URL{ua, ub} {
+		if u.Path == "" {
+			u.Path = "/"
+		}
+		u.Fragment = ""
+	}
+	return ua.String() == ub.String()
+}
+
+// bodyCapture keeps the first bytes read from a body streamed through other consumers, to fingerprint it
+type bodyCapture struct {
+	buf   bytes.Buffer
+	limit int
+	// teed is the body the consumers read from
+	teed io.Reader
+}
+
+func (b *bodyCapture) Write(p []byte) (int, error) {
+	if room := b.limit - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// capturingForSoft404 tees a complete 200 response body into a capture, if soft 404s are detected
+func (c *URLCheckerClient) capturingForSoft404(response *resty.Response, body io.Reader) (*bodyCapture, io.Reader) {
+	if !c.settings.DetectSoft404 || response == nil || response.StatusCode() != http.StatusOK {
+		return nil, body
+	}
+	capture := &bodyCapture{limit: soft404MaxBodyBytes}
+	capture.teed = io.TeeReader(body, capture)
+	return capture, capture.teed
+}
+
+// fingerprint reads the rest of the captured part of the body, the other consumers possibly having stopped early
+func (b *bodyCapture) fingerprint() *pageFingerprint {
+	_, _ = io.Copy(io.Discard, io.LimitReader(b.teed, int64(b.limit-b.buf.Len())))
+	return fingerprintOf(b.buf.String())
+}
+
+func fingerprintOf(body string) *pageFingerprint {
+	return &pageFingerprint{
+		size:    len(body),
+		title:   titleOf(body),
+		simhash: simhashOf(body),
+	}
+}
+
+func (p *pageFingerprint) resembles(other *pageFingerprint) bool {
+	if p.title != other.title {
+		return false
+	}
+	larger := max(p.size, other.size)
+	if larger > 0 && float64(abs(p.size-other.size))/float64(larger) > soft404MaxSizeDeviation {
+		return false
+	}
+	return bits.OnesCount64(p.simhash^other.simhash) <= soft404MaxSimhashDistance
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func titleOf(body string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(body))
+	inTitle := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken:
+			name, _ := tokenizer.TagName()
+			inTitle = string(name) == "title"
+		case html.TextToken:
+			if inTitle {
+				return strings.TrimSpace(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			inTitle = false
+		}
+	}
+}
+
+// simhashOf computes a 64-bit simhash over word shingles, so that similar documents
+// differ in only a few bits
+func simhashOf(body string) uint64 {
+	words := strings.Fields(body)
+	if len(words) == 0 {
+		return 0
+	}
+	var weights [64]int
+	for i := 0; i+soft404ShingleSize <= len(words) || i == 0; i++ {
+		end := min(i+soft404ShingleSize, len(words))
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strings.Join(words[i:end], " ")))
+		sum := h.Sum64()
+		for bit := range 64 {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var simhash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			simhash |= 1 << bit
+		}
+	}
+	return simhash
+}
@@ -0,0 +1,129 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func cmsPage(title, content string) string {
+	return fmt.Sprintf("<html><head><title>%v</title></head><body><nav>Home Products About Contact</nav>%v<footer>Copyright</footer></body></html>",
+		title, content)
+}
+
+func TestFingerprintsOfSimilarPages(t *testing.T) {
+	notFound := strings.Repeat("Sorry, the page you were looking for could not be found. ", 10)
+	a := fingerprintOf(cmsPage("Not found", notFound+"/a"))
+	b := fingerprintOf(cmsPage("Not found", notFound+"/bcd"))
+	assert.Equal(t, "Not found", a.title)
+	assert.True(t, a.resembles(b))
+
+	article := fingerprintOf(cmsPage("Release notes", strings.Repeat("The release contains many fixes and new features. ", 10)))
+	assert.False(t, a.resembles(article))
+}
+
+func TestDetectingSoft404s(t *testing.T) {
+	notFound := cmsPage("Not found", strings.Repeat("Sorry, the page you were looking for could not be found. ", 10))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/article" {
+			_, _ = w.Write([]byte(cmsPage("Article", strings.Repeat("An actual article about link checking. ", 10))))
+			return
+		}
+		_, _ = w.Write([]byte(notFound))
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	ResetGlobalStats()
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL+"/removed")
+	assert.Equal(t, Ok, res.Status, "soft 404s should not be detected by default")
+
+	viper.Set("detectSoft404", true)
+	c := NewURLCheckerClient()
+	res = c.CheckURL(context.Background(), ts.URL+"/removed")
+	assert.Equal(t, Soft404, res.Status)
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.NotNil(t, res.Error)
+
+	res = c.CheckURL(context.Background(), ts.URL+"/article")
+	assert.Equal(t, Ok, res.Status)
+
+	assert.Equal(t, int64(1), GlobalStats().GetDomainStats().DomainStats["127.0.0.1"].BrokenBecause["soft_404"])
+}
+
+func TestHostsWithProper404sAreNotProbedTwice(t *testing.T) {
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/lcs-soft-404-probe-") {
+			probes.Add(1)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("detectSoft404", true)
+	c := NewURLCheckerClient()
+	assert.Equal(t, Ok, c.CheckURL(context.Background(), ts.URL+"/a").Status)
+	assert.Equal(t, Ok, c.CheckURL(context.Background(), ts.URL+"/b").Status)
+	assert.Equal(t, int32(1), probes.Load())
+}
+
+func TestHomePagesOfHostsRedirectingNonExistentPathsAreNotSoft404s(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(cmsPage("Home", strings.Repeat("Welcome to our site. ", 10))))
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("detectSoft404", true)
+	c := NewURLCheckerClient()
+	assert.Equal(t, Ok, c.CheckURL(context.Background(), ts.URL+"/").Status)
+	assert.Equal(t, Ok, c.CheckURL(context.Background(), ts.URL).Status)
+	assert.Equal(t, Soft404, c.CheckURL(context.Background(), ts.URL+"/removed").Status,
+		"removed pages redirected to the home page are still soft 404s")
+}
+
+func TestSoft404DetectionReusesTheBodyOfTheCheck(t *testing.T) {
+	var pageRequests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/lcs-soft-404-probe-") {
+			_, _ = w.Write([]byte(cmsPage("Not found", "Sorry.")))
+			return
+		}
+		pageRequests.Add(1)
+		_, _ = w.Write([]byte(cmsPage("Article", strings.Repeat("An actual article about link checking. ", 10))))
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("detectSoft404", true)
+	viper.Set("searchForBodyPatterns", true)
+	viper.Set("bodyPatterns", []BodyPatternConfig{{Name: "article", Regex: "article"}})
+	t.Cleanup(func() { viper.Set("bodyPatterns", nil) })
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), ts.URL+"/article")
+	assert.Equal(t, Ok, res.Status)
+	assert.Equal(t, []string{"article"}, res.BodyPatternsFound)
+	assert.Equal(t, int32(2), pageRequests.Load(), "only the HEAD and GET requests of the check")
+}
@@ -31,6 +31,13 @@ type Stats struct {
 	LinkChecksSkipped      int64
 	CacheHits              int64
 	CacheMisses            int64
+	// CacheRevalidations counts the expired ok results confirmed by a 304 Not Modified response
+	CacheRevalidations int64
+	// BytesTransferred is the number of response body bytes read by the checks
+	BytesTransferred int64
+	// ConnectionsOpened and ConnectionsReused count the connections used by the requests of the checks
+	ConnectionsOpened int64
+	ConnectionsReused int64
 }
 
 // DomainStatsResponse for all domains
@@ -40,8 +47,9 @@ type DomainStatsResponse struct {
 
 // DomainStats for one domain
 type DomainStats struct {
-	BrokenBecause map[string]int64
-	Ok            int64
+	BrokenBecause    map[string]int64
+	Ok               int64
+	BytesTransferred int64
 }
 
 // StatsState is the protected instance of the Stats object
@@ -100,6 +108,14 @@ func (stats *StatsState) OnLinkErrored(domain string) {
 	stats.Unlock()
 }
 
+// OnLinkErroredBecause called on link check error attributed to the checker setup, e.g. a proxy rejecting the credentials
+func (stats *StatsState) OnLinkErroredBecause(domain string, status string) {
+	stats.Lock()
+	stats.s.LinkChecksErrored++
+	stats.incrementOrDefaultStatus(domain, status)
+	stats.Unlock()
+}
+
 // OnLinkOk called on link check ok
 func (stats *StatsState) OnLinkOk(domain string) {
 	stats.Lock()
@@ -138,6 +154,30 @@ func (stats *StatsState) OnLinkSkipped(domain string) {
 	stats.Unlock()
 }
 
+// OnBytesTransferred called with the number of response body bytes read by a check
+func (stats *StatsState) OnBytesTransferred(domain string, n int64) {
+	if n == 0 {
+		return
+	}
+	stats.Lock()
+	stats.s.BytesTransferred += n
+	ds := stats.domainStatsOf(domain)
+	ds.BytesTransferred += n
+	stats.d[domain] = ds
+	stats.Unlock()
+}
+
+// OnConnection called when a request got a connection, either a new or a kept-alive one
+func (stats *StatsState) OnConnection(reused bool) {
+	stats.Lock()
+	if reused {
+		stats.s.ConnectionsReused++
+	} else {
+		stats.s.ConnectionsOpened++
+	}
+	stats.Unlock()
+}
+
 // OnCacheHit called when the result is taken from the cache
 func (stats *StatsState) OnCacheHit() {
 	stats.Lock()
@@ -152,6 +192,13 @@ func (stats *StatsState) OnCacheMiss() {
 	stats.Unlock()
 }
 
+// OnCacheRevalidation called when an expired result was confirmed to be unchanged via a conditional request
+func (stats *StatsState) OnCacheRevalidation() {
+	stats.Lock()
+	stats.s.CacheRevalidations++
+	stats.Unlock()
+}
+
 // GetStats returns a copy of the stats
 func (stats *StatsState) GetStats() Stats {
 	stats.RLock()
@@ -166,27 +213,29 @@ func (stats *StatsState) GetDomainStats() DomainStatsResponse {
 	deepClone := make(map[string]DomainStats)
 	for k, v := range stats.d {
 		deepClone[k] = DomainStats{
-			Ok:            v.Ok,
-			BrokenBecause: maps.Clone(v.BrokenBecause),
+			Ok:               v.Ok,
+			BrokenBecause:    maps.Clone(v.BrokenBecause),
+			BytesTransferred: v.BytesTransferred,
 		}
 	}
 	return DomainStatsResponse{deepClone} // a copy
 }
 
-func (stats *StatsState) incrementOrDefaultOk(domain string) {
+func (stats *StatsState) domainStatsOf(domain string) DomainStats {
 	if _, ok := stats.d[domain]; !ok {
 		stats.d[domain] = defaultDomainStats()
 	}
-	ds := stats.d[domain]
+	return stats.d[domain]
+}
+
+func (stats *StatsState) incrementOrDefaultOk(domain string) {
+	ds := stats.domainStatsOf(domain)
 	ds.Ok++
 	stats.d[domain] = ds
 }
 
 func (stats *StatsState) incrementOrDefaultStatus(domain string, status string) {
-	if _, ok := stats.d[domain]; !ok {
-		stats.d[domain] = defaultDomainStats()
-	}
-	ds := stats.d[domain]
+	ds := stats.domainStatsOf(domain)
 	ds.BrokenBecause = incrementOrDefaultBrokenBecause(ds.BrokenBecause, status)
 	stats.d[domain] = ds
 }
@@ -34,12 +34,16 @@ func TestCollectingFromSeveralGoroutines(t *testing.T) {
 		LinkChecksSkipped:      expectedCount,
 		CacheHits:              expectedCount,
 		CacheMisses:            expectedCount,
+		BytesTransferred:       expectedCount,
+		ConnectionsOpened:      expectedCount,
+		ConnectionsReused:      expectedCount,
 	}, s)
 
 	assert.Equal(t, map[string]DomainStats{
 		"example.com": {
-			BrokenBecause: map[string]int64{}, // not nil!
-			Ok:            expectedCount,
+			BrokenBecause:    map[string]int64{}, // not nil!
+			Ok:               expectedCount,
+			BytesTransferred: expectedCount,
 		},
 		"notfound.com": {
 			BrokenBecause: map[string]int64{
@@ -140,6 +144,10 @@ func addStats(numGoroutines int, count int) {
 				s.OnLinkSkipped("skipped.com")
 				s.OnCacheHit()
 				s.OnCacheMiss()
+				s.OnBytesTransferred("example.com", 1)
+				s.OnBytesTransferred("example.com", 0)
+				s.OnConnection(true)
+				s.OnConnection(false)
 			}
 			defer wg.Done()
 		}()
@@ -0,0 +1,100 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const defaultCertificateExpiryWarningDays = 14
+
+// TLSInfo is the internal struct to hold the inspected peer certificate of an HTTPS check
+type TLSInfo struct {
+	Version         string
+	Subject         string
+	Issuer          string
+	DNSNames        []string
+	HostnameMatch   bool
+	NotAfter        time.Time
+	DaysUntilExpiry int
+	// ChainError is the reason the certificate chain could not be validated, if any
+	ChainError string
+}
+
+// peerCertificatesOf returns the certificates presented by the server, also if their verification failed
+func peerCertificatesOf(response *resty.Response, err error) ([]*x509.Certificate, *tls.ConnectionState, error) {
+	var verificationError *tls.CertificateVerificationError
+	if errors.As(err, &verificationError) {
+		return verificationError.UnverifiedCertificates, nil, verificationError.Err
+	}
+	if response == nil || response.RawResponse == nil || response.RawResponse.TLS == nil {
+		return nil, nil, nil
+	}
+	state := response.RawResponse.TLS
+	return state.PeerCertificates, state, nil
+}
+
+func (c *URLCheckerClient) inspectCertificates(res *URLCheckResult, host string, response *resty.Response, err error) *URLCheckResult {
+	if !c.settings.InspectCertificates {
+		return res
+	}
+	certificates, state, verificationErr := peerCertificatesOf(response, err)
+	if len(certificates) == 0 {
+		return res
+	}
+	info := tlsInfoOf(certificates, host, time.Now())
+	if state != nil {
+		info.Version = tls.VersionName(state.Version)
+	}
+
+	switch {
+	case verificationErr != nil:
+		info.ChainError = verificationErr.Error()
+	case c.settings.SkipCertificateCheck:
+		// the client did not verify the chain -> do it here for the report
+		if err := verifyChain(certificates, host); err != nil {
+			info.ChainError = err.Error()
+		}
+	}
+
+	res.TLS = info
+	if info.DaysUntilExpiry < int(c.settings.CertificateExpiryWarningDays) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("certificate of %v expires in %v days", host, info.DaysUntilExpiry))
+	}
+	return res
+}
+
+func tlsInfoOf(certificates []*x509.Certificate, host string, now time.Time) *TLSInfo {
+	leaf := certificates[0]
+	return &TLSInfo{
+		Subject:         leaf.Subject.String(),
+		Issuer:          leaf.Issuer.String(),
+		DNSNames:        leaf.DNSNames,
+		HostnameMatch:   leaf.VerifyHostname(host) == nil,
+		NotAfter:        leaf.NotAfter,
+		DaysUntilExpiry: int(math.Floor(leaf.NotAfter.Sub(now).Hours() / 24)),
+	}
+}
+
+func verifyChain(certificates []*x509.Certificate, host string) error {
+	intermediates := x509.NewCertPool()
+	for _, cert := range certificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Intermediates: intermediates,
+	})
+	return err
+}
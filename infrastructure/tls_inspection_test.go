@@ -0,0 +1,58 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspectingCertificates(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+	assert.Nil(t, res.TLS, "certificates should not be inspected by default")
+
+	viper.Set("HTTPClient.inspectCertificates", true)
+
+	t.Run("unverified certificates are still reported", func(t *testing.T) {
+		res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+		assert.Equal(t, Broken, res.Status)
+		require.NotNil(t, res.TLS)
+		assert.NotEmpty(t, res.TLS.ChainError)
+		assert.True(t, res.TLS.HostnameMatch)
+	})
+
+	t.Run("certificates are validated when skipping the certificate check", func(t *testing.T) {
+		viper.Set("HTTPClient.skipCertificateCheck", true)
+		res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+		assert.Equal(t, Ok, res.Status)
+		require.NotNil(t, res.TLS)
+		assert.NotEmpty(t, res.TLS.Version)
+		assert.NotEmpty(t, res.TLS.ChainError, "the test server certificate is self-signed")
+		assert.Greater(t, res.TLS.DaysUntilExpiry, 0)
+		assert.Empty(t, res.Warnings)
+	})
+
+	t.Run("expiring certificates are flagged", func(t *testing.T) {
+		viper.Set("HTTPClient.certificateExpiryWarningDays", uint(1_000_000))
+		res := NewURLCheckerClient().CheckURL(context.Background(), ts.URL)
+		assert.Equal(t, Ok, res.Status)
+		require.Len(t, res.Warnings, 1)
+		assert.Contains(t, res.Warnings[0], "expires in")
+	})
+}
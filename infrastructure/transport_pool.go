@@ -0,0 +1,138 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	netUrl "net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const defaultMaxIdleConnsPerHost = 4
+const defaultIdleConnTimeout = 90 * time.Second
+
+// transportPoolSettings bound the kept-alive connections of each pooled transport
+type transportPoolSettings struct {
+	MaxIdleConnsPerHost uint
+	// IdleConnTimeout is the time after which idle connections are closed
+	IdleConnTimeout time.Duration
+}
+
+func loadTransportPoolFromViper(s *urlCheckerSettings) {
+	s.TransportPool = transportPoolSettings{
+		MaxIdleConnsPerHost: defaultMaxIdleConnsPerHost,
+		IdleConnTimeout:     defaultIdleConnTimeout,
+	}
+	if v := viper.GetUint("HTTPClient.maxIdleConnsPerHost"); v > 0 {
+		s.TransportPool.MaxIdleConnsPerHost = v
+	}
+	if viper.GetString("HTTPClient.idleConnTimeout") != "" {
+		s.TransportPool.IdleConnTimeout = viperDuration("HTTPClient.idleConnTimeout", defaultIdleConnTimeout)
+	}
+	log.Info().Msgf("HTTP client MaxIdleConnsPerHost: %v", s.TransportPool.MaxIdleConnsPerHost)
+}
+
+// transportKey identifies the settings a connection depends on: the effective proxy and the TLS settings
+type transportKey struct {
+	proxyURL             string
+	impersonation        string
+	skipCertificateCheck bool
+}
+
+func transportKeyOf(settings urlCheckerSettings) transportKey {
+	key := transportKey{
+		proxyURL:             settings.ProxyURL,
+		skipCertificateCheck: settings.SkipCertificateCheck,
+	}
+	if settings.Impersonation != nil {
+		key.impersonation = settings.Impersonation.Name
+	}
+	return key
+}
+
+// transportPool shares the transports, and thus the kept-alive connections, among the clients with the same key
+type transportPool struct {
+	transports sync.Map
+}
+
+func newTransportPool() *transportPool {
+	return &transportPool{}
+}
+
+func (p *transportPool) transportFor(settings urlCheckerSettings) *http.Transport {
+	if p == nil {
+		return newTransport(settings)
+	}
+	key := transportKeyOf(settings)
+	if transport, ok := p.transports.Load(key); ok {
+		return transport.(*http.Transport)
+	}
+	transport, loaded := p.transports.LoadOrStore(key, newTransport(settings))
+	if !loaded {
+		log.Debug().Msgf("Pooled a transport for proxy '%v', impersonation '%v'", key.proxyURL, key.impersonation)
+	}
+	return transport.(*http.Transport)
+}
+
+func newTransport(settings urlCheckerSettings) *http.Transport {
+	dialer := &net.Dialer{
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                  http.ProxyFromEnvironment,
+		DialContext:            dialer.DialContext,
+		ForceAttemptHTTP2:      true,
+		MaxIdleConns:           100,
+		MaxIdleConnsPerHost:    int(settings.TransportPool.MaxIdleConnsPerHost),
+		IdleConnTimeout:        settings.TransportPool.IdleConnTimeout,
+		TLSHandshakeTimeout:    settings.Timeouts.TLSHandshake,
+		ResponseHeaderTimeout:  settings.Timeouts.ResponseHeader,
+		ExpectContinueTimeout:  1 * time.Second,
+		TLSClientConfig:        tlsConfigOf(settings),
+		OnProxyConnectResponse: onProxyConnectResponse,
+	}
+	if settings.Resolver != nil {
+		transport.DialContext = settings.Resolver.DialContext
+	}
+	transport.DialContext = dialContextWithTimeout(transport.DialContext, settings.Timeouts.Connect)
+	if settings.ProxyURL != "" {
+		proxyURL, err := netUrl.Parse(settings.ProxyURL)
+		if err != nil {
+			log.Error().Err(err).Msgf("Could not parse the proxy URL")
+		} else {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	transport.Proxy = settings.ProxyCredentials.applyTo(transport.Proxy)
+	return transport
+}
+
+func tlsConfigOf(settings urlCheckerSettings) *tls.Config {
+	if p := settings.Impersonation; p != nil {
+		tlsConfig := p.TLSConfig()
+		// This is known to be insecure, thus protected via a configuration with a secure default.
+		tlsConfig.InsecureSkipVerify = settings.SkipCertificateCheck
+		return tlsConfig
+	}
+	if settings.SkipCertificateCheck {
+		// This is known to be insecure, thus protected via a configuration with a secure default.
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	return nil
+}
+
+func newHTTPClient(settings urlCheckerSettings) *http.Client {
+	return &http.Client{
+		Transport: &cookieTransport{next: settings.Transports.transportFor(settings), jars: settings.CookieJars},
+	}
+}
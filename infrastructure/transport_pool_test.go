@@ -0,0 +1,75 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionsAreReused(t *testing.T) {
+	var newConnections atomic.Int64
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConnections.Add(1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	setUpViperTestConfiguration()
+	c := NewURLCheckerClient()
+	ResetGlobalStats()
+	for i := 0; i < 5; i++ {
+		res := c.CheckURL(context.Background(), fmt.Sprintf("%v/page-%d", ts.URL, i))
+		assert.Equal(t, Ok, res.Status)
+	}
+
+	assert.Equal(t, int64(1), newConnections.Load())
+	stats := GlobalStats().GetStats()
+	assert.Equal(t, int64(1), stats.ConnectionsOpened)
+	assert.Equal(t, int64(4), stats.ConnectionsReused)
+}
+
+func TestTransportsArePooledByProxyAndTLSSettings(t *testing.T) {
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.maxIdleConnsPerHost", uint(2))
+	viper.Set("HTTPClient.idleConnTimeout", "5s")
+	settings := getURLCheckerSettings()
+	pool := settings.Transports
+
+	transport := pool.transportFor(settings)
+	assert.Same(t, transport, pool.transportFor(settings))
+	assert.Equal(t, 2, transport.MaxIdleConnsPerHost)
+	assert.Equal(t, 5*time.Second, transport.IdleConnTimeout)
+
+	proxied := settings
+	proxied.ProxyURL = "http://proxy.example.com:8080"
+	assert.NotSame(t, transport, pool.transportFor(proxied))
+	assert.Same(t, pool.transportFor(proxied), pool.transportFor(proxied))
+
+	insecure := settings
+	insecure.SkipCertificateCheck = true
+	assert.NotSame(t, transport, pool.transportFor(insecure))
+	assert.True(t, pool.transportFor(insecure).TLSClientConfig.InsecureSkipVerify)
+
+	impersonating := settings
+	impersonating.ImpersonateProfile = "firefox"
+	impersonating.Impersonation = impersonating.impersonationFor("https://example.com")
+	assert.NotSame(t, transport, pool.transportFor(impersonating))
+	assert.NotNil(t, pool.transportFor(impersonating).TLSClientConfig)
+}
@@ -8,7 +8,6 @@ package infrastructure
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -19,10 +18,9 @@ import (
 
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/darren/gpac"
-
 	"github.com/patrickmn/go-cache"
 
 	"github.com/spf13/viper"
@@ -30,9 +28,13 @@ import (
 	netUrl "net/url"
 
 	"github.com/go-resty/resty/v2"
+	"github.com/gobwas/glob"
+
+	"github.com/siemens/link-checker-service/infrastructure/impersonate"
 )
 
 const defaultLimitBodyToNBytes = 0
+const defaultRangeFallbackBytes = 1024
 const defaultMaxRedirectsCount = 15
 const defaultTimeoutSeconds = 10
 const defaultUserAgent = "lcs/0.9"
@@ -56,17 +58,72 @@ type URLCheckResult struct {
 	RemoteAddr            string
 	CheckerTrace          []URLCheckerPluginTrace
 	ElapsedMs             int64
+	// ErrorCategory classifies the failure of requests that got no response
+	ErrorCategory ErrorCategory
+	// AvailableAnchors lists the anchors found in the document if the URL fragment was not among them
+	AvailableAnchors []string
+	// Redirects is the redirect chain followed by the request that produced the result
+	Redirects []URLRedirect
+	FinalURL  string
+	// TLS holds the inspected peer certificate of HTTPS checks when `inspectCertificates` is configured
+	TLS *TLSInfo
+	// Warnings do not make the link broken, but might require attention
+	Warnings []string
+	// SkipReason is a machine-readable reason for a skipped check
+	SkipReason string
+	// BodyBytesInspected is the number of response body bytes streamed through the body patterns
+	BodyBytesInspected int64
+	// BytesTransferred is the number of response body bytes read during the check
+	BytesTransferred int64
+	// Policy is the name of the check policy applied, if any
+	Policy string
+	// Headers holds the allow-listed headers of the response the result is based on
+	Headers map[string]string
+	// Timing breaks down the duration of the request the result is based on
+	Timing *RequestTiming
+	// Revalidated is true if a cached result was confirmed by a 304 Not Modified response
+	Revalidated bool
+	// ContentHash is the hash of the normalized content if `detectContentChanges` is configured
+	ContentHash string
+	// PreviousContentHash is the content hash of the previous check of the URL, if known
+	PreviousContentHash string
+	ContentChanged      bool
+
+	// brokenBecause overrides the status code as the reason counted in the domain stats
+	brokenBecause string
+	// proxy is the label of the proxy the check went through, if any
+	proxy string
+	// retryAfter is the delay requested by the target via the Retry-After header
+	retryAfter time.Duration
+	// attempts traces the individual attempts of a checker plugin, if retries are enabled
+	attempts []URLCheckerPluginTrace
+	// validators of the response, sent when revalidating the cached result
+	validators *validators
+	// notModified is true if the resource did not change since the cached result being revalidated
+	notModified bool
+	// fingerprint of the body of the GET request, reused by the soft 404 detection
+	fingerprint *pageFingerprint
 }
 
 // BodyPatternConfig is unmarshalled from the configuration file
 type BodyPatternConfig struct {
 	Name  string
 	Regex string
+	// Effect of a match on successful responses: report (default), mark_broken or mark_requires_auth
+	Effect string
+	// Domain is an optional glob limiting the domains to search for the pattern
+	Domain string
 }
 
 type bodyPattern struct {
 	name    string
 	pattern *regexp.Regexp
+	effect  bodyPatternEffect
+	// domain is nil for patterns searched on all domains
+	domain glob.Glob
+	// anchoredAtBeginning and anchoredAtEnd restrict the body chunks the pattern is matched against
+	anchoredAtBeginning bool
+	anchoredAtEnd       bool
 }
 
 type urlCheckerSettings struct {
@@ -78,12 +135,60 @@ type urlCheckerSettings struct {
 	AcceptHeader          string
 	SkipCertificateCheck  bool
 	SearchForBodyPatterns bool
+	CheckAnchors          bool
+	DetectSoft404         bool
 	BodyPatterns          []bodyPattern
 	EnableRequestTracing  bool
 	URLCheckerPlugins     []string
 	PacScriptURL          string
 	LimitBodyToNBytes     uint
 	ImpersonateProfile    string
+	ImpersonateDomains    []impersonateDomainRule
+	ImpersonateProfiles   map[string]impersonate.Profile
+	Retries               retryPolicy
+	// Impersonation is the profile applied by buildClient
+	Impersonation *impersonate.Profile
+
+	InspectCertificates bool
+	// CertificateExpiryWarningDays is the threshold below which an expiring certificate is flagged
+	CertificateExpiryWarningDays uint
+
+	// RangeFallbackBytes is the number of bytes requested via GET if HEAD requests fail, and no body patterns are searched
+	RangeFallbackBytes uint
+	// PacScriptRefreshInterval is the interval the PAC script is checked for changes at, never if 0
+	PacScriptRefreshInterval time.Duration
+
+	// ProxyCredentials are attached to the static and the PAC-selected proxies, nil if none are configured
+	ProxyCredentials *proxyCredentials
+
+	RespectRobotsTxt bool
+	// RobotsTxtExemptDomains are checked regardless of their robots.txt, e.g. internal hosts
+	RobotsTxtExemptDomains []glob.Glob
+
+	// CheckPolicies are matched against each URL in order, the first matching one applies
+	CheckPolicies []*checkPolicy
+
+	// CaptureResponseHeaders are the canonical names of the response headers stored in the results
+	CaptureResponseHeaders []string
+
+	// Timeouts limit the phases of each request, in addition to TimeoutSeconds
+	Timeouts phaseTimeouts
+
+	// ContentHash configures the content change detection, nil if disabled
+	ContentHash *contentHashSettings
+
+	// CookieJars are shared by all clients built from the settings
+	CookieJars *cookieJars
+
+	TransportPool transportPoolSettings
+	// Transports are shared by all clients built from the settings
+	Transports *transportPool
+
+	DNS resolverSettings
+	// Resolver is used by the dialers of the clients built by buildClient
+	Resolver *dnsResolver
+
+	Credentials []credentialRule
 }
 
 // URLChecker interface that all layers should conform to
@@ -96,7 +201,16 @@ type URLCheckerClient struct {
 	settings       urlCheckerSettings
 	dnsCache       *cache.Cache
 	checkerPlugins []URLCheckerPlugin
-	autoProxy      *gpac.Parser
+	autoProxy      *pacScript
+	// anchors caches the anchors parsed per document URL, so that many fragments cost only one fetch
+	anchors *sharedFetchCache[[]string]
+	// soft404Probes caches the fingerprint of a non-existent page per host, nil if the host responds properly
+	soft404Probes *sharedFetchCache[*pageFingerprint]
+	resolver      *dnsResolver
+	// robotsTxts caches the robots.txt rules per origin
+	robotsTxts *sharedFetchCache[*robotsTxt]
+	// schemeHandlers check the URLs of non-HTTP schemes
+	schemeHandlers map[string]schemeHandler
 }
 
 // NewURLCheckerClient instantiates a new basic URL checking client
@@ -104,12 +218,18 @@ func NewURLCheckerClient() *URLCheckerClient {
 	urlCheckerSettings := getURLCheckerSettings()
 
 	c := &URLCheckerClient{
-		settings: urlCheckerSettings,
-		dnsCache: cache.New(defaultCacheExpirationInterval, defaultCacheCleanupInterval),
+		settings:      urlCheckerSettings,
+		dnsCache:      cache.New(defaultCacheExpirationInterval, defaultCacheCleanupInterval),
+		anchors:       newSharedFetchCache[[]string](defaultCacheExpirationInterval),
+		soft404Probes: newSharedFetchCache[*pageFingerprint](defaultCacheExpirationInterval),
+		resolver:      urlCheckerSettings.Resolver,
+		robotsTxts:    newSharedFetchCache[*robotsTxt](defaultCacheExpirationInterval),
 	}
+	c.schemeHandlers = newSchemeHandlers(c)
 
 	if c.settings.PacScriptURL != "" {
-		c.autoProxy = parsePacScript(c.settings.PacScriptURL)
+		c.autoProxy = newPacScript(c.settings.PacScriptURL, c.settings.PacScriptRefreshInterval,
+			time.Duration(c.settings.TimeoutSeconds)*time.Second)
 	}
 
 	checkers := buildCheckerPlugins(c, urlCheckerSettings)
@@ -144,13 +264,13 @@ func appendConfiguredChecker(checkers []URLCheckerPlugin, c *URLCheckerClient, c
 func appendHTTPCheckerPlugin(checkers []URLCheckerPlugin, c *URLCheckerClient, checkerName string, urlCheckerSettings urlCheckerSettings) []URLCheckerPlugin {
 	switch checkerName {
 	case checkerPluginURLCheck:
-		checkers = addChecker(checkers, newLocalURLChecker(c, checkerPluginURLCheck, buildClient(urlCheckerSettings)))
+		checkers = addChecker(checkers, newLocalURLChecker(c, checkerPluginURLCheck, urlCheckerSettings, buildClient(urlCheckerSettings)))
 		log.Info().Msg("Added the defaut URL checker")
 	case checkerPluginURLCheckPAC:
 		if c.settings.PacScriptURL == "" {
 			panic("Cannot instantiate a 'urlcheck-pac' checker without a proxy auto-config script configured")
 		}
-		checkers = addChecker(checkers, newLocalURLChecker(c, checkerPluginURLCheckPAC, nil))
+		checkers = addChecker(checkers, newLocalURLChecker(c, checkerPluginURLCheckPAC, urlCheckerSettings, nil))
 		log.Info().Msg("Added the PAC file based URL checker")
 	case checkerPluginURLCheckNoProxy:
 		if urlCheckerSettings.ProxyURL == "" {
@@ -158,7 +278,7 @@ func appendHTTPCheckerPlugin(checkers []URLCheckerPlugin, c *URLCheckerClient, c
 		}
 		urlCheckerSettingsNoProxy := urlCheckerSettings
 		urlCheckerSettingsNoProxy.ProxyURL = ""
-		checkers = addChecker(checkers, newLocalURLChecker(c, checkerPluginURLCheckNoProxy, buildClient(urlCheckerSettingsNoProxy)))
+		checkers = addChecker(checkers, newLocalURLChecker(c, checkerPluginURLCheckNoProxy, urlCheckerSettingsNoProxy, buildClient(urlCheckerSettingsNoProxy)))
 		log.Info().Msg("Added the URL checker that doesn't use a proxy")
 	}
 	return checkers
@@ -200,20 +320,6 @@ func appendTestDoubleCheckerPlugin(checkers []URLCheckerPlugin, checkerName stri
 	return checkers
 }
 
-func parsePacScript(scriptURL string) *gpac.Parser {
-	res, err := resty.New().R().Get(scriptURL)
-	if err != nil {
-		panic(fmt.Errorf("could not fetch a PAC script from %v: %v", scriptURL, err.Error()))
-	}
-	log.Info().Msgf("Read PAC script from %v", scriptURL)
-	script := string(res.Body())
-	pac, err := gpac.New(script)
-	if err != nil {
-		panic(fmt.Errorf("could not parse the PAC script: %v", err.Error()))
-	}
-	return pac
-}
-
 func addChecker(checkers []URLCheckerPlugin, plugin URLCheckerPlugin) []URLCheckerPlugin {
 	if plugin != nil {
 		return append(checkers, plugin)
@@ -230,14 +336,19 @@ func defaultURLCheckerSettings() urlCheckerSettings {
 		BrowserUserAgent:  defaultBrowserUserAgent,
 		AcceptHeader:      defaultAcceptHeader,
 		LimitBodyToNBytes: defaultLimitBodyToNBytes,
+
+		CertificateExpiryWarningDays: defaultCertificateExpiryWarningDays,
+		RangeFallbackBytes:           defaultRangeFallbackBytes,
 	}
 }
 
 func applyProxyAndPacFromViper(s *urlCheckerSettings) {
 	if proxyURL := viper.GetString("proxy"); proxyURL != "" {
-		_, err := netUrl.Parse(proxyURL)
+		u, err := netUrl.Parse(proxyURL)
 		if err != nil {
 			log.Warn().Err(err).Msgf("Rejected proxyURL: %v", proxyURL)
+		} else if !isSupportedProxyScheme(u.Scheme) {
+			log.Warn().Msgf("Rejected proxyURL with an unsupported scheme: %v", proxyURL)
 		} else {
 			log.Info().Msgf("URLCheckerClient is using a proxy: %v", proxyURL)
 			s.ProxyURL = proxyURL
@@ -245,12 +356,21 @@ func applyProxyAndPacFromViper(s *urlCheckerSettings) {
 	}
 	if pacScriptURL := viper.GetString("pacScriptURL"); pacScriptURL != "" {
 		s.PacScriptURL = pacScriptURL
+		s.PacScriptRefreshInterval = defaultPacScriptRefreshInterval
+		if viper.GetString("pacScriptRefreshInterval") != "" {
+			s.PacScriptRefreshInterval = viperDuration("pacScriptRefreshInterval", defaultPacScriptRefreshInterval)
+		}
 	}
 }
 
 func applyHTTPClientFieldsFromViper(s *urlCheckerSettings) {
-	s.MaxRedirectsCount = viper.GetUint("HTTPClient.maxRedirectsCount")
+	if v := viper.GetUint("HTTPClient.maxRedirectsCount"); v > 0 {
+		s.MaxRedirectsCount = v
+	}
 	s.LimitBodyToNBytes = viper.GetUint("HTTPClient.limitBodyToNBytes")
+	if v := viper.GetUint("HTTPClient.rangeFallbackBytes"); v > 0 {
+		s.RangeFallbackBytes = v
+	}
 	s.TimeoutSeconds = viper.GetUint("HTTPClient.timeoutSeconds")
 	if v := viper.GetString("HTTPClient.userAgent"); v != "" {
 		s.UserAgent = v
@@ -263,6 +383,10 @@ func applyHTTPClientFieldsFromViper(s *urlCheckerSettings) {
 	}
 	s.SkipCertificateCheck = viper.GetBool("HTTPClient.skipCertificateCheck")
 	s.EnableRequestTracing = viper.GetBool("HTTPClient.enableRequestTracing")
+	s.InspectCertificates = viper.GetBool("HTTPClient.inspectCertificates")
+	if v := viper.GetUint("HTTPClient.certificateExpiryWarningDays"); v > 0 {
+		s.CertificateExpiryWarningDays = v
+	}
 }
 
 func logURLCheckerHTTPSettings(s urlCheckerSettings) {
@@ -274,10 +398,15 @@ func logURLCheckerHTTPSettings(s urlCheckerSettings) {
 	log.Info().Msgf("HTTP client SkipCertificateCheck: %v", s.SkipCertificateCheck)
 	log.Info().Msgf("HTTP client EnableRequestTracing: %v", s.EnableRequestTracing)
 	log.Info().Msgf("HTTP client LimitBodyToNBytes: %v", s.LimitBodyToNBytes)
+	log.Info().Msgf("HTTP client RangeFallbackBytes: %v", s.RangeFallbackBytes)
+	log.Info().Msgf("HTTP client InspectCertificates: %v", s.InspectCertificates)
+	if s.InspectCertificates {
+		log.Info().Msgf("HTTP client CertificateExpiryWarningDays: %v", s.CertificateExpiryWarningDays)
+	}
 }
 
 func loadBodyPatternsFromViper(s *urlCheckerSettings) {
-	if !s.SearchForBodyPatterns {
+	if !s.SearchForBodyPatterns && !checkPoliciesSearchBodyPatterns() {
 		return
 	}
 	log.Info().Msg("Will search for regex patterns found in HTTP response bodies")
@@ -287,11 +416,24 @@ func loadBodyPatternsFromViper(s *urlCheckerSettings) {
 	}
 	for _, pattern := range configBodyPatterns {
 		r := regexp.MustCompile(pattern.Regex)
+		effect := mustBeBodyPatternEffect(pattern.Effect)
+		var domain glob.Glob
+		if pattern.Domain != "" {
+			domain = glob.MustCompile(pattern.Domain)
+		}
+		anchoredAtBeginning, anchoredAtEnd := textAnchorsOf(pattern.Regex)
+		if anchoredAtBeginning && anchoredAtEnd {
+			log.Warn().Msgf("Body search pattern '%v' is anchored at both ends of the body: it only matches bodies up to %v bytes", pattern.Name, bodyPatternChunkBytes)
+		}
 		s.BodyPatterns = append(s.BodyPatterns, bodyPattern{
-			name:    pattern.Name,
-			pattern: r,
+			name:                pattern.Name,
+			pattern:             r,
+			effect:              effect,
+			domain:              domain,
+			anchoredAtBeginning: anchoredAtBeginning,
+			anchoredAtEnd:       anchoredAtEnd,
 		})
-		log.Info().Msgf("Body search pattern found. Name: '%v', Regex: '%v'", pattern.Name, pattern.Regex)
+		log.Info().Msgf("Body search pattern found. Name: '%v', Regex: '%v', Effect: '%v', Domain: '%v'", pattern.Name, pattern.Regex, effect, pattern.Domain)
 	}
 }
 
@@ -309,10 +451,32 @@ func urlCheckerPluginsFromViper() []string {
 func getURLCheckerSettings() urlCheckerSettings {
 	s := defaultURLCheckerSettings()
 	applyProxyAndPacFromViper(&s)
+	loadProxyCredentialsFromViper(&s)
 	applyHTTPClientFieldsFromViper(&s)
 	logURLCheckerHTTPSettings(s)
 	s.SearchForBodyPatterns = viper.GetBool("searchForBodyPatterns")
+	s.CheckAnchors = viper.GetBool("checkAnchors")
+	if s.CheckAnchors {
+		log.Info().Msg("Will validate URL fragments against the anchors of HTML documents")
+	}
+	s.DetectSoft404 = viper.GetBool("detectSoft404")
+	if s.DetectSoft404 {
+		log.Info().Msg("Will detect pages resembling the 'not found' pages of their hosts")
+	}
 	loadBodyPatternsFromViper(&s)
+	loadImpersonationFromViper(&s)
+	loadRetryPolicyFromViper(&s)
+	loadResolverFromViper(&s)
+	loadCredentialsFromViper(&s)
+	loadTransportPoolFromViper(&s)
+	loadRobotsTxtFromViper(&s)
+	loadCheckPoliciesFromViper(&s)
+	loadCapturedResponseHeadersFromViper(&s)
+	loadPhaseTimeoutsFromViper(&s)
+	loadContentHashFromViper(&s)
+	loadCookieJarsFromViper(&s)
+	s.Resolver = newDNSResolver(s.DNS)
+	s.Transports = newTransportPool()
 	s.URLCheckerPlugins = urlCheckerPluginsFromViper()
 	return s
 }
@@ -334,18 +498,24 @@ func (l *fakeURLChecker) CheckURL(_ context.Context, url string, _ *URLCheckResu
 	return l.alwaysReturn, true /* aborts the chain for now */
 }
 
-func newLocalURLChecker(c *URLCheckerClient, name string, client *resty.Client) *localURLChecker {
+func newLocalURLChecker(c *URLCheckerClient, name string, settings urlCheckerSettings, client *resty.Client) *localURLChecker {
 	return &localURLChecker{
-		c:      c,
-		client: client,
-		name:   name,
+		c:        c,
+		client:   client,
+		settings: settings,
+		name:     name,
 	}
 }
 
 type localURLChecker struct {
-	c      *URLCheckerClient
-	client *resty.Client
-	name   string
+	c        *URLCheckerClient
+	client   *resty.Client
+	settings urlCheckerSettings
+	name     string
+	// clientVariants holds a lazily built client per impersonation profile name and check policy timeout
+	clientVariants sync.Map
+	// pacClients holds a lazily built client per proxy and impersonation chosen via the PAC script, and per check policy timeout
+	pacClients sync.Map
 }
 
 func (l *localURLChecker) Name() string {
@@ -353,16 +523,22 @@ func (l *localURLChecker) Name() string {
 }
 
 func (l *localURLChecker) CheckURL(ctx context.Context, urlToCheck string, lastResult *URLCheckResult) (*URLCheckResult, bool) {
-	if lastResult == nil || shouldRetryBasedOnStatus(lastResult.Code) {
-		client := l.client
-		if client == nil && l.c.settings.PacScriptURL != "" {
-			client = l.autoSelectClientFor(urlToCheck)
+	policy := checkPolicyOf(ctx)
+	if lastResult == nil || policy.shouldTryNextChecker(lastResult) {
+		if l.client == nil && l.c.settings.PacScriptURL != "" {
+			res, err := l.checkViaPACRoutes(ctx, urlToCheck)
+			onCheckResult(DomainOf(urlToCheck), res)
+			return res, err
 		}
+		client := l.clientFor(urlToCheck, policy)
 		if client == nil {
 			panic("cannot instantiate a HTTP client. Please check the configuration")
 		}
-		GlobalStats().OnOutgoingRequest()
-		res, err := l.c.checkURL(ctx, urlToCheck, client)
+		proxy := ""
+		if l.settings.ProxyURL != "" {
+			proxy = proxyLabelOf(l.settings.ProxyURL)
+		}
+		res, err := l.checkWithRetries(ctx, urlToCheck, client, proxy)
 		onCheckResult(DomainOf(urlToCheck), res)
 		return res, err
 	}
@@ -379,30 +555,58 @@ func onCheckResult(domain string, res *URLCheckResult) {
 	case Ok:
 		s.OnLinkOk(domain)
 	case Broken:
-		s.OnLinkBroken(domain, fmt.Sprintf("%v", res.Code))
+		s.OnLinkBroken(domain, brokenBecauseOf(res))
+	case BrokenAnchor, Soft404, RequiresAuth:
+		s.OnLinkBroken(domain, res.Status.String())
 	case Dropped:
-		// handled in the drop handler
+		if res.ErrorCategory == ErrorCategoryProxyAuthRequired {
+			s.OnLinkErroredBecause(domain, string(res.ErrorCategory))
+		}
+		// otherwise handled in the drop handler
 	case Skipped:
 		// handled in an upper layer
 		break
 	}
 }
 
-func (l *localURLChecker) autoSelectClientFor(urlToCheck string) *resty.Client {
-	tmpSettings := l.c.settings
-	proxies, err := l.c.autoProxy.FindProxy(urlToCheck)
-	if err == nil && len(proxies) > 0 {
-		// choosing the first available proxy
-		for _, proxy := range proxies {
-			if proxy.Type == "PROXY" {
-				tmpSettings.ProxyURL = proxies[0].URL()
-				break
-			}
-		}
-	} else {
-		log.Warn().Msgf("Could not find a proxy for %v", sanitizeUserLogInput(urlToCheck))
+// brokenBecauseOf is the error category, the found body pattern, or the status code for broken responses
+func brokenBecauseOf(res *URLCheckResult) string {
+	if res.ErrorCategory != "" {
+		return string(res.ErrorCategory)
 	}
-	return buildClient(tmpSettings)
+	if res.brokenBecause != "" {
+		return res.brokenBecause
+	}
+	return fmt.Sprintf("%v", res.Code)
+}
+
+// clientVariantKey identifies a client differing from the default one by the impersonation profile or the timeout
+type clientVariantKey struct {
+	impersonation  string
+	timeoutSeconds uint
+}
+
+func (l *localURLChecker) clientFor(urlToCheck string, policy *checkPolicy) *resty.Client {
+	if l.client == nil {
+		return nil
+	}
+	profile := l.settings.impersonationFor(urlToCheck)
+	timeoutSeconds := policy.timeoutOr(l.settings.TimeoutSeconds)
+	if profile == nil && timeoutSeconds == l.settings.TimeoutSeconds {
+		return l.client
+	}
+	key := clientVariantKey{timeoutSeconds: timeoutSeconds}
+	if profile != nil {
+		key.impersonation = profile.Name
+	}
+	if client, ok := l.clientVariants.Load(key); ok {
+		return client.(*resty.Client)
+	}
+	tmpSettings := l.settings
+	tmpSettings.Impersonation = profile
+	tmpSettings.TimeoutSeconds = timeoutSeconds
+	client, _ := l.clientVariants.LoadOrStore(key, buildClient(tmpSettings))
+	return client.(*resty.Client)
 }
 
 // URLCheckerPluginTrace is the internal struct to gather individual checker plugin stats
@@ -411,6 +615,14 @@ type URLCheckerPluginTrace struct {
 	Code      int
 	ElapsedMs int64
 	Error     string
+	// Attempt is the 1-based attempt number if retries are enabled
+	Attempt int
+	// BytesTransferred is the number of response body bytes read
+	BytesTransferred int64
+	// Proxy is the proxy used, or DIRECT for a direct connection chosen via the PAC script
+	Proxy string
+	// Timing breaks down the duration of the request the attempt's result is based on
+	Timing *RequestTiming
 }
 
 func checkerTraceEntry(checker URLCheckerPlugin, res *URLCheckResult, elapsed time.Duration) URLCheckerPluginTrace {
@@ -423,11 +635,21 @@ func checkerTraceEntry(checker URLCheckerPlugin, res *URLCheckResult, elapsed ti
 		Code:      res.Code,
 		ElapsedMs: int64(elapsed / time.Millisecond),
 		Error:     errMsg,
+
+		BytesTransferred: res.BytesTransferred,
+		Proxy:            res.proxy,
+		Timing:           res.Timing,
 	}
 }
 
 // CheckURL checks a single URL
 func (c *URLCheckerClient) CheckURL(ctx context.Context, url string) *URLCheckResult {
+	if res := c.checkNonHTTPScheme(ctx, url); res != nil {
+		return res
+	}
+	policy := c.settings.checkPolicyFor(url)
+	ctx = withCookieJar(withCheckPolicy(ctx, policy))
+
 	var lastRes *URLCheckResult
 	var checkerTrace []URLCheckerPluginTrace
 	start := time.Now()
@@ -435,7 +657,11 @@ func (c *URLCheckerClient) CheckURL(ctx context.Context, url string) *URLCheckRe
 	for pos, currentChecker := range c.checkerPlugins {
 		checkerStart := time.Now()
 		res, shouldAbort := currentChecker.CheckURL(ctx, url, lastRes)
-		checkerTrace = append(checkerTrace, checkerTraceEntry(currentChecker, res, time.Since(checkerStart)))
+		if res != nil && len(res.attempts) > 0 {
+			checkerTrace = append(checkerTrace, res.attempts...)
+		} else {
+			checkerTrace = append(checkerTrace, checkerTraceEntry(currentChecker, res, time.Since(checkerStart)))
+		}
 
 		if pos == 0 && res == nil {
 			panic("first checker should never return nil")
@@ -443,7 +669,7 @@ func (c *URLCheckerClient) CheckURL(ctx context.Context, url string) *URLCheckRe
 
 		lastRes = res
 
-		if shouldAbort || !shouldRetryBasedOnStatus(lastRes.Code) {
+		if shouldAbort || !policy.shouldTryNextChecker(lastRes) {
 			break
 		}
 	}
@@ -451,7 +677,9 @@ func (c *URLCheckerClient) CheckURL(ctx context.Context, url string) *URLCheckRe
 	if lastRes != nil {
 		result := *lastRes
 		result.CheckerTrace = checkerTrace
+		result.attempts = nil
 		result.ElapsedMs = int64(time.Since(start) / time.Millisecond)
+		result.Policy = policy.name
 		return &result
 	}
 
@@ -467,9 +695,9 @@ func normalizeAddressOf(input string) string {
 	port := u.Port()
 	if port == "" {
 		switch u.Scheme {
-		case "http":
+		case "http", "ws":
 			port = "80"
-		case "https":
+		case "https", "wss":
 			port = "443"
 		}
 		return u.Host + ":" + port
@@ -484,8 +712,8 @@ func (c *URLCheckerClient) maybeWithRequestTrace(ctx context.Context, urlToCheck
 	}
 	domain := DomainOf(urlToCheck)
 	trace := &httptrace.ClientTrace{
-		ConnectDone: func(network, _addr string, err error) {
-			*remoteAddr = c.resolveAndCacheTCPAddr(network, err, addrToResolve)
+		ConnectDone: func(_, _addr string, err error) {
+			*remoteAddr = c.resolveAndCacheTCPAddr(err, addrToResolve)
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
 			if *remoteAddr == "" {
@@ -519,39 +747,107 @@ func (c *URLCheckerClient) checkURL(ctx context.Context, urlToCheck string, clie
 		// do not block if not cancelled
 	}
 
+	if res := c.robotsTxtDisallowed(ctx, urlToCheck, client); res != nil {
+		return res, true
+	}
+
 	addrToResolve := normalizeAddressOf(urlToCheck)
 	remoteAddr := c.cachedRemoteAddr(addrToResolve)
 	ctx = c.maybeWithRequestTrace(ctx, urlToCheck, addrToResolve, &remoteAddr)
 
-	res := c.dispatchHeadRequests(ctx, urlToCheck, client)
-	res = c.tryGetRequestAndProcessResponseBody(ctx, urlToCheck, client, res)
+	if isWebSocketURL(urlToCheck) {
+		res := c.checkWebSocket(ctx, urlToCheck, client)
+		res.RemoteAddr = remoteAddr
+		return res, false
+	}
+
+	policy := checkPolicyOf(ctx)
+	var res *URLCheckResult
+	if policy.method != checkMethodGet {
+		res = policy.acceptingOkStatus(c.dispatchHeadRequests(ctx, urlToCheck, client))
+	}
+	if policy.method != checkMethodHead && (res == nil || !res.notModified) {
+		res = c.tryGetRequestAndProcessResponseBody(ctx, urlToCheck, client, res)
+	}
+	// the anchors and soft 404 verdicts of the cached result still apply to an unchanged resource
+	if !res.notModified {
+		res = c.validateAnchor(ctx, urlToCheck, client, res)
+		res = c.detectSoft404(ctx, urlToCheck, client, res)
+	}
 	res.RemoteAddr = remoteAddr
 	return res, false
 }
 
+// tryGetRequestAndProcessResponseBody sends a GET request if the HEAD request failed, or if body patterns
+// are searched for, or the content is hashed. res is nil if no HEAD request was sent.
 func (c *URLCheckerClient) tryGetRequestAndProcessResponseBody(ctx context.Context, urlToCheck string, client *resty.Client, res *URLCheckResult) *URLCheckResult {
-	var body string
+	policy := checkPolicyOf(ctx)
+	bodyPatterns, searchBodyPatterns := policy.bodyPatternsOf(c.settings)
+	hashContent := c.settings.ContentHash != nil
+	if res != nil && !searchBodyPatterns && !hashContent && (res.Status == Ok || !policy.shouldRetryBasedOnStatus(res.Code)) {
+		return res
+	}
+
 	// some sites don't allow HEAD requests, try a GET
-	if c.settings.SearchForBodyPatterns ||
-		shouldRetryBasedOnStatus(res.Code) {
-		response, err := client.R().
-			SetHeader("Accept", c.settings.AcceptHeader).
-			SetContext(ctx).
-			SetDoNotParseResponse(true).
-			SetHeader("User-Agent", c.settings.BrowserUserAgent).
-			Get(urlToCheck)
-		res = c.processResponse(urlToCheck, response, err)
-		if c.settings.SearchForBodyPatterns && response != nil {
-			body = c.limitedBody(response)
-		}
+	req := applyValidatorsTo(ctx, c.newRequest(ctx, client, urlToCheck, c.settings.BrowserUserAgent)).
+		SetDoNotParseResponse(true)
+	// without body patterns, only the first bytes are requested, not to download large files
+	ranged := !searchBodyPatterns && !hashContent
+	if ranged {
+		req.SetHeader("Range", fmt.Sprintf("bytes=0-%d", c.settings.RangeFallbackBytes-1))
+	}
+	response, err := req.Get(urlToCheck)
+	res = policy.acceptingOkStatus(c.processResponse(urlToCheck, response, err))
+	if ranged {
+		res = acceptingUnsatisfiableRange(res)
 	}
 
-	if c.settings.SearchForBodyPatterns {
-		res = c.searchForBodyPatterns(res, body)
+	body := &countingReader{r: http.NoBody}
+	if response != nil && response.RawBody() != nil {
+		rawBody := c.bodyOf(response)
+		body.r = rawBody
+		defer func() { _ = rawBody.Close() }()
+	}
+	var content io.Reader = c.limitedReader(body)
+	// ranged responses only hold the first bytes of the page
+	var capture *bodyCapture
+	if !ranged {
+		capture, content = c.capturingForSoft404(response, content)
+	}
+	if hashContent {
+		res, content = c.withContentHash(res, response, content)
+	}
+	if searchBodyPatterns {
+		res = c.searchForBodyPatterns(urlToCheck, bodyPatterns, res, content)
+	} else if !hashContent {
+		c.drainRangeResponse(response, body)
+	}
+	if capture != nil {
+		res.fingerprint = capture.fingerprint()
+	}
+	res.BytesTransferred = body.n
+	if timing := timingOf(response); timing != nil {
+		res.Timing = timing
 	}
 	return res
 }
 
+// newRequest prepares a request with the configured headers. Headers already set on the client,
+// e.g. by an impersonation profile, take precedence. The credentials configured for the domain are attached.
+func (c *URLCheckerClient) newRequest(ctx context.Context, client *resty.Client, urlToCheck, userAgent string) *resty.Request {
+	req := client.R().SetContext(withRequestTimer(withConnectionTracker(withRedirectRecorder(ctx))))
+	if client.Header.Get("Accept") == "" {
+		req.SetHeader("Accept", c.settings.AcceptHeader)
+	}
+	if client.Header.Get("User-Agent") == "" {
+		req.SetHeader("User-Agent", userAgent)
+	}
+	if rule := c.settings.credentialsFor(urlToCheck); rule != nil {
+		rule.applyTo(req)
+	}
+	return req
+}
+
 func shouldRetryBasedOnStatus(code int) bool {
 	if code < 300 {
 		return false
@@ -568,21 +864,18 @@ func shouldRetryBasedOnStatus(code int) bool {
 }
 
 func (c *URLCheckerClient) tryHeadRequestDefault(ctx context.Context, urlToCheck string, client *resty.Client) *URLCheckResult {
-	response, err := client.R().
-		SetHeader("Accept", c.settings.AcceptHeader).
-		SetHeader("User-Agent", c.settings.UserAgent).
-		SetContext(ctx).
+	response, err := applyValidatorsTo(ctx, c.newRequest(ctx, client, urlToCheck, c.settings.UserAgent)).
 		Head(urlToCheck)
 
 	res := c.processResponse(urlToCheck, response, err)
 	return res
 }
 
-func (c *URLCheckerClient) resolveAndCacheTCPAddr(network string, err error, addrToResolve string) string {
+func (c *URLCheckerClient) resolveAndCacheTCPAddr(err error, addrToResolve string) string {
 	remoteAddr := ""
 	domain := DomainOf(addrToResolve)
 	if err == nil {
-		if addr, err := net.ResolveTCPAddr(network, addrToResolve); err == nil {
+		if addr, err := c.resolver.ResolveTCPAddr(context.Background(), addrToResolve); err == nil {
 			// this may be called multiple times: last invocation wins
 			remoteAddr = addr.String()
 			c.dnsCache.Set(addrToResolve, remoteAddr, defaultCacheExpirationInterval)
@@ -627,15 +920,13 @@ func interpretOkDespiteHeadTransportError(err error, response *resty.Response, n
 	}
 }
 
-func brokenResultFromRequestFailure(err error, nowEpoch int64) *URLCheckResult {
+func brokenResultFromRequestFailure(err error, connected bool, nowEpoch int64) *URLCheckResult {
 	code := CustomHTTPErrorCode /*as there's no available status in this case*/
-	msg := "no error specified"
-	if err != nil {
-		msg = strings.ToLower(err.Error())
+	category := errorCategoryOf(err, connected)
+	if category == ErrorCategoryProxyAuthRequired {
+		return proxyAuthRequiredResult(err, nowEpoch)
 	}
-	if strings.Contains(msg, "bad gateway") ||
-		strings.Contains(msg, "timeout") ||
-		strings.Contains(msg, "deadline") {
+	if category.isGatewayFailure() {
 		code = http.StatusBadGateway
 	}
 	return &URLCheckResult{
@@ -644,10 +935,21 @@ func brokenResultFromRequestFailure(err error, nowEpoch int64) *URLCheckResult {
 		Error:                 err,
 		FetchedAtEpochSeconds: nowEpoch,
 		BodyPatternsFound:     []string{},
+		ErrorCategory:         category,
 	}
 }
 
 func (c *URLCheckerClient) processResponse(url string, response *resty.Response, err error) *URLCheckResult {
+	res := c.withResponseHeaders(withRedirectsOf(resultFromResponse(url, response, err), response), response)
+	res.Timing = timingOf(response)
+	finalURL := url
+	if res.FinalURL != "" {
+		finalURL = res.FinalURL
+	}
+	return c.inspectCertificates(res, DomainOf(finalURL), response, err)
+}
+
+func resultFromResponse(url string, response *resty.Response, err error) *URLCheckResult {
 	nowEpoch := time.Now().Unix()
 
 	// some browser-optimized cache-controlled CDN sites return an empty body if browser doesn't re-request
@@ -656,11 +958,20 @@ func (c *URLCheckerClient) processResponse(url string, response *resty.Response,
 	}
 
 	if err != nil || response == nil {
-		return brokenResultFromRequestFailure(err, nowEpoch)
+		connected := response != nil && response.Request != nil && connectedIn(response.Request.Context())
+		return brokenResultFromRequestFailure(err, connected, nowEpoch)
 	}
 
 	statusCode := response.StatusCode()
 
+	if statusCode == http.StatusProxyAuthRequired {
+		return proxyAuthRequiredResult(fmt.Errorf("proxy authentication required for url '%v'", url), nowEpoch)
+	}
+
+	if res := notModifiedResult(response, nowEpoch); res != nil {
+		return res
+	}
+
 	if statusCode >= 300 {
 		return &URLCheckResult{
 			Status:                Broken,
@@ -668,6 +979,7 @@ func (c *URLCheckerClient) processResponse(url string, response *resty.Response,
 			Error:                 fmt.Errorf("%v status on url '%v'", statusCode, url),
 			FetchedAtEpochSeconds: nowEpoch,
 			BodyPatternsFound:     []string{},
+			retryAfter:            retryAfterOf(response.Header()),
 		}
 	}
 
@@ -676,97 +988,43 @@ func (c *URLCheckerClient) processResponse(url string, response *resty.Response,
 		Code:                  statusCode,
 		FetchedAtEpochSeconds: nowEpoch,
 		BodyPatternsFound:     []string{},
+		validators:            validatorsOf(response),
 	}
 }
 
-func (c *URLCheckerClient) searchForBodyPatterns(res *URLCheckResult, body string) *URLCheckResult {
-	for _, pattern := range c.settings.BodyPatterns {
-		if pattern.pattern.MatchString(body) {
-			res.BodyPatternsFound = append(res.BodyPatternsFound, pattern.name)
-		}
-	}
-	return res
-}
-
 func (c *URLCheckerClient) tryHeadRequestAsBrowserIfForbidden(ctx context.Context, urlToCheck string, client *resty.Client, res *URLCheckResult) *URLCheckResult {
 	// Some sites don't allow robot user agents
 	if res.Code == http.StatusForbidden {
-		response, err := client.R().
-			SetHeader("Accept", c.settings.AcceptHeader).
-			SetHeader("User-Agent", c.settings.BrowserUserAgent).
-			SetContext(ctx).
+		response, err := applyValidatorsTo(ctx, c.newRequest(ctx, client, urlToCheck, c.settings.BrowserUserAgent)).
 			Head(urlToCheck)
 		res = c.processResponse(urlToCheck, response, err)
 	}
 	return res
 }
 
-func (c *URLCheckerClient) limitedBody(response *resty.Response) string {
-	body := response.RawBody()
-	if body == nil {
-		return ""
+// limitedReader applies the configured body size limit to a stream
+func (c *URLCheckerClient) limitedReader(body io.Reader) io.Reader {
+	if c.settings.LimitBodyToNBytes == 0 {
+		return body
 	}
-	defer func() { _ = body.Close() }()
-	return safelyTrimmedStream(body, c.settings.LimitBodyToNBytes)
-}
-
-func safelyTrimmedStream(input io.Reader, limit uint) string {
-	if limit == 0 {
-		return readAllSafe(input)
-	}
-	return readLimited(input, limit)
+	return io.LimitReader(body, int64(c.settings.LimitBodyToNBytes))
 }
 
+// readAllSafe returns the input read until the end, or until the first error
 func readAllSafe(input io.Reader) string {
-	b, err := io.ReadAll(input)
-	if err != nil {
-		if b != nil {
-			return string(safelyTrimmedString(b, 0))
-		}
-		return ""
-	}
+	b, _ := io.ReadAll(input)
 	return string(b)
 }
 
-func readLimited(input io.Reader, limit uint) string {
-	const bufferSize = 1024
-	var res []byte
-	b := [bufferSize]byte{}
-	bytesRead := 0
-	for {
-		n, err := input.Read(b[:])
-		res = append(res, b[:n]...)
-		if err != nil {
-			return string(safelyTrimmedString(res, limit))
-		}
-		bytesRead += n
-		if uint(bytesRead) >= limit {
-			break
-		}
-	}
-	return string(safelyTrimmedString(res, limit))
-}
-
-func safelyTrimmedString(s []byte, limit uint) []byte {
-	if limit == 0 || len(s) <= int(limit) {
-		return s
-	}
-	return s[:limit]
-}
-
+// buildClient creates a client on top of a pooled transport shared by the clients with the same proxy and TLS settings
 func buildClient(settings urlCheckerSettings) *resty.Client {
-	client := resty.New()
+	client := resty.NewWithClient(newHTTPClient(settings))
 	client.SetTimeout(time.Second * time.Duration(settings.TimeoutSeconds))
-	client.SetCloseConnection(true)
-	client.SetRedirectPolicy(resty.FlexibleRedirectPolicy(defaultMaxRedirectsCount))
-	if settings.ProxyURL != "" {
-		client.SetProxy(settings.ProxyURL)
-	}
-
-	if settings.SkipCertificateCheck {
-		// This is known to be insecure, thus protected via a configuration with a secure default.
-		client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
-	}
-
+	client.SetRedirectPolicy(
+		recordingRedirectPolicy(),
+		maxRedirectsPolicy(int(settings.MaxRedirectsCount)),
+		credentialsRedirectPolicy(settings.Credentials),
+	)
+	applyImpersonation(client, settings)
 	return client
 }
@@ -78,12 +78,47 @@ func TestNormalizingAddresses(t *testing.T) {
 func setUpViperTestConfiguration() {
 	viper.SetEnvPrefix("LCS")
 	viper.Set("proxy", os.Getenv("LCS_PROXY"))
+	viper.Set("pacScriptURL", "")
+	viper.Set("pacScriptRefreshInterval", "")
+	viper.Set("proxyUsername", "")
+	viper.Set("proxyPassword", "")
+	viper.Set("respectRobotsTxt", false)
+	viper.Set("robotsTxtExemptDomains", nil)
+	viper.Set("checkPolicies", nil)
+	viper.Set("captureResponseHeaders", nil)
+	viper.Set("HTTPClient.connectTimeout", "")
+	viper.Set("HTTPClient.tlsHandshakeTimeout", "")
+	viper.Set("HTTPClient.responseHeaderTimeout", "")
+	viper.Set("HTTPClient.bodyReadTimeout", "")
+	viper.Set("cacheExpirationInterval", "")
+	viper.Set("cacheRevalidationWindow", "")
+	viper.Set("detectContentChanges", false)
+	viper.Set("contentSelector", "")
+	viper.Set("contentIgnorePatterns", nil)
+	viper.Set("contentHashRetention", "")
+	viper.Set("persistentCookieDomains", nil)
 	viper.Set("HTTPClient.timeoutSeconds", uint(15))
 	viper.Set("HTTPClient.maxRedirectsCount", uint(15))
 	viper.Set("HTTPClient.enableRequestTracing", false)
 	viper.Set("HTTPClient.limitBodyToNBytes", uint(0))
+	viper.Set("HTTPClient.rangeFallbackBytes", uint(0))
+	viper.Set("HTTPClient.maxIdleConnsPerHost", uint(0))
+	viper.Set("HTTPClient.idleConnTimeout", "")
 	viper.Set("searchForBodyPatterns", false)
+	viper.Set("checkAnchors", false)
+	viper.Set("detectSoft404", false)
+	viper.Set("HTTPClient.retryAttempts", uint(0))
+	viper.Set("HTTPClient.inspectCertificates", false)
+	viper.Set("HTTPClient.certificateExpiryWarningDays", uint(0))
+	viper.Set("HTTPClient.dnsServer", "")
+	viper.Set("HTTPClient.dnsOverHTTPSURL", "")
+	viper.Set("HTTPClient.resolve", []string{})
+	viper.Set("credentials", nil)
 	viper.Set("urlCheckerPlugins", []string{})
+	viper.Set("HTTPClient.skipCertificateCheck", false)
+	viper.Set("HTTPClient.impersonateProfile", "")
+	viper.Set("impersonateDomains", nil)
+	viper.Set("impersonateProfiles", nil)
 	patterns := []struct {
 		Name  string
 		Regex string
@@ -191,50 +226,6 @@ func TestLimitingBodyReading(t *testing.T) {
 	)
 }
 
-func Test_safelyTrimmedStream(t *testing.T) {
-	t.Run("limiting empty input produces empty string", func(t *testing.T) {
-		assert.Equal(t, "", safelyTrimmedStream(streamOf(""), 10))
-	})
-
-	t.Run("non-empty input is not limited if no limit configured", func(t *testing.T) {
-		assert.Equal(t, testStringToLimit, safelyTrimmedStream(streamOf(testStringToLimit), 0))
-	})
-
-	t.Run("limiting input to a size smaller than a chunk returns string of the limit length",
-		func(t *testing.T) {
-			assert.Equal(t, startChunk, safelyTrimmedStream(streamOf(testStringToLimit), uint(len(startChunk))))
-		})
-
-	t.Run("limiting input to a size larger than itself returns the original string",
-		func(t *testing.T) {
-			assert.Equal(t, testStringToLimit, safelyTrimmedStream(streamOf(testStringToLimit), 2000))
-		})
-
-	t.Run("limiting input to one byte results in one character",
-		func(t *testing.T) {
-			assert.Equal(t, 1, len(safelyTrimmedStream(streamOf(testStringToLimit), 1)))
-		})
-
-	t.Run("limiting input larger than the buffer (1kB) to a limit larger than the buffer trims the input",
-		func(t *testing.T) {
-			assert.Equal(t, 1200, len(safelyTrimmedStream(streamOf(
-				strings.Repeat(testStringToLimit, 2),
-			), 1200)))
-		})
-
-	t.Run("trimming the errored stream returns the input processed", func(t *testing.T) {
-		assert.Equal(t, "abc", safelyTrimmedStream(faultyReaderOf(
-			"abc,d", 3,
-		), 10))
-	})
-
-	t.Run("untrimmed errored stream returns the input processed", func(t *testing.T) {
-		assert.Equal(t, "abc", safelyTrimmedStream(faultyReaderOf(
-			"abc,d", 3,
-		), 0))
-	})
-}
-
 type faultyReader struct {
 	input   string
 	errorAt int
@@ -253,7 +244,3 @@ func faultyReaderOf(s string, i int) io.Reader {
 		errorAt: i,
 	}
 }
-
-func streamOf(s string) io.Reader {
-	return strings.NewReader(s)
-}
@@ -4,7 +4,7 @@
 // file, You can obtain one at https://mozilla.org/MPL/2.0/.
 // SPDX-License-Identifier: MPL-2.0
 //go:generate go install github.com/alvaroloes/enumer@master
-//go:generate enumer -type=URLCheckStatus -json -text -transform=lower
+//go:generate enumer -type=URLCheckStatus -json -text -transform=snake
 
 package infrastructure
 
@@ -22,4 +22,10 @@ const (
 	Broken
 	// Dropped indicates an internal reason for not proceeding with the URL check
 	Dropped
+	// BrokenAnchor indicates the document is accessible, but the URL fragment does not point to an anchor within it
+	BrokenAnchor
+	// Soft404 indicates the server responded successfully with a page resembling its "not found" page
+	Soft404
+	// RequiresAuth indicates the server responded successfully with a page matching a `mark_requires_auth` body pattern
+	RequiresAuth
 )
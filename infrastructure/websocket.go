@@ -0,0 +1,108 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	netUrl "net/url"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// webSocketGUID is appended to the key of the handshake to compute the accept value, see RFC 6455
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// webSocketSchemes map the WebSocket schemes to the schemes of their opening handshakes
+var webSocketSchemes = map[string]string{
+	"ws":  "http",
+	"wss": "https",
+}
+
+func isWebSocketURL(urlToCheck string) bool {
+	u, err := netUrl.Parse(urlToCheck)
+	return err == nil && webSocketSchemes[strings.ToLower(u.Scheme)] != ""
+}
+
+// handshakeURLOf returns the http(s) URL the opening handshake of a WebSocket URL is sent to, other URLs unchanged
+func handshakeURLOf(urlToCheck string) string {
+	u, err := netUrl.Parse(urlToCheck)
+	if err != nil {
+		return urlToCheck
+	}
+	scheme, ok := webSocketSchemes[strings.ToLower(u.Scheme)]
+	if !ok {
+		return urlToCheck
+	}
+	u.Scheme = scheme
+	return u.String()
+}
+
+// checkWebSocket sends the opening handshake, and closes the connection right after a successful upgrade
+func (c *URLCheckerClient) checkWebSocket(ctx context.Context, urlToCheck string, client *resty.Client) *URLCheckResult {
+	key := newWebSocketKey()
+	subprotocol := checkPolicyOf(ctx).webSocketSubprotocol
+	req := c.newRequest(ctx, client, urlToCheck, c.settings.UserAgent).
+		SetDoNotParseResponse(true).
+		SetHeader("Connection", "Upgrade").
+		SetHeader("Upgrade", "websocket").
+		SetHeader("Sec-WebSocket-Version", "13").
+		SetHeader("Sec-WebSocket-Key", key)
+	if subprotocol != "" {
+		req.SetHeader("Sec-WebSocket-Protocol", subprotocol)
+	}
+	response, err := req.Get(handshakeURLOf(urlToCheck))
+	res := c.processResponse(urlToCheck, response, err)
+	if response == nil || response.RawBody() == nil {
+		return res
+	}
+	// closing the body of an upgraded connection closes the connection
+	defer func() { _ = response.RawBody().Close() }()
+	if res.Status != Ok {
+		return res
+	}
+	if err := verifyWebSocketHandshake(response, key, subprotocol); err != nil {
+		res.Status = Broken
+		res.Error = fmt.Errorf("WebSocket handshake with '%v' failed: %w", urlToCheck, err)
+		res.brokenBecause = "websocket_handshake"
+	}
+	return res
+}
+
+func newWebSocketKey() string {
+	key := make([]byte, 16)
+	_, _ = rand.Read(key)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func webSocketAcceptOf(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// verifyWebSocketHandshake requires a 101 Switching Protocols response accepting the key, and the subprotocol, if any
+func verifyWebSocketHandshake(response *resty.Response, key, subprotocol string) error {
+	if response.StatusCode() != http.StatusSwitchingProtocols {
+		return fmt.Errorf("the server responded with %v instead of switching protocols", response.StatusCode())
+	}
+	header := response.Header()
+	if !strings.EqualFold(header.Get("Upgrade"), "websocket") {
+		return fmt.Errorf("the server switched to '%v'", header.Get("Upgrade"))
+	}
+	if header.Get("Sec-WebSocket-Accept") != webSocketAcceptOf(key) {
+		return fmt.Errorf("invalid Sec-WebSocket-Accept header")
+	}
+	if subprotocol != "" && header.Get("Sec-WebSocket-Protocol") != subprotocol {
+		return fmt.Errorf("the subprotocol '%v' was not accepted", subprotocol)
+	}
+	return nil
+}
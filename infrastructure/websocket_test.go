@@ -0,0 +1,167 @@
+// Copyright 2020-2024 Siemens AG
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+// SPDX-License-Identifier: MPL-2.0
+
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webSocketHandler upgrades requests to /socket, accepting the given subprotocol, and reports
+// when the client closed the connection. /plain does not upgrade.
+func webSocketHandler(subprotocol string, closed chan<- struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/socket" {
+			if r.URL.Path != "/plain" {
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") || r.Header.Get("Sec-WebSocket-Version") != "13" {
+			w.WriteHeader(http.StatusUpgradeRequired)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %v\r\n",
+			webSocketAcceptOf(r.Header.Get("Sec-WebSocket-Key")))
+		if subprotocol != "" && r.Header.Get("Sec-WebSocket-Protocol") == subprotocol {
+			_, _ = fmt.Fprintf(rw, "Sec-WebSocket-Protocol: %v\r\n", subprotocol)
+		}
+		_, _ = rw.WriteString("\r\n")
+		_ = rw.Flush()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := io.Copy(io.Discard, conn); err == nil {
+			closed <- struct{}{}
+		}
+	}
+}
+
+func webSocketURLOf(ts *httptest.Server, scheme, path string) string {
+	return scheme + strings.TrimPrefix(strings.TrimPrefix(ts.URL, "http"), "s") + path
+}
+
+func TestWebSocketHandshakes(t *testing.T) {
+	closed := make(chan struct{}, 10)
+	ts := httptest.NewServer(webSocketHandler("", closed))
+	defer ts.Close()
+	setUpViperTestConfiguration()
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), webSocketURLOf(ts, "ws", "/socket"))
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.Code)
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		assert.Fail(t, "the connection was not closed after the handshake")
+	}
+
+	res = c.CheckURL(context.Background(), webSocketURLOf(ts, "ws", "/plain"))
+	assert.Equal(t, Broken, res.Status)
+	assert.Contains(t, res.Error.Error(), "instead of switching protocols")
+
+	res = c.CheckURL(context.Background(), webSocketURLOf(ts, "ws", "/missing"))
+	assert.Equal(t, Broken, res.Status)
+	assert.Equal(t, http.StatusNotFound, res.Code)
+}
+
+func TestSecureWebSocketHandshakes(t *testing.T) {
+	closed := make(chan struct{}, 10)
+	ts := httptest.NewTLSServer(webSocketHandler("", closed))
+	defer ts.Close()
+	setUpViperTestConfiguration()
+	viper.Set("HTTPClient.skipCertificateCheck", true)
+
+	res := NewURLCheckerClient().CheckURL(context.Background(), webSocketURLOf(ts, "wss", "/socket"))
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Equal(t, http.StatusSwitchingProtocols, res.Code)
+}
+
+func TestWebSocketSubprotocols(t *testing.T) {
+	closed := make(chan struct{}, 10)
+	ts := httptest.NewServer(webSocketHandler("graphql-ws", closed))
+	defer ts.Close()
+	setUpViperTestConfiguration()
+	viper.Set("checkPolicies", []CheckPolicyConfig{
+		{Name: "graphql", URL: "/socket\\?graphql$", WebSocketSubprotocol: "graphql-ws"},
+		{Name: "mqtt", URL: "/socket\\?mqtt$", WebSocketSubprotocol: "mqtt"},
+	})
+	c := NewURLCheckerClient()
+
+	res := c.CheckURL(context.Background(), webSocketURLOf(ts, "ws", "/socket?graphql"))
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+
+	res = c.CheckURL(context.Background(), webSocketURLOf(ts, "ws", "/socket?mqtt"))
+	assert.Equal(t, Broken, res.Status)
+	assert.Contains(t, res.Error.Error(), "'mqtt' was not accepted")
+}
+
+func TestWebSocketHandshakesViaPACRoutes(t *testing.T) {
+	closed := make(chan struct{}, 10)
+	ts := httptest.NewServer(webSocketHandler("", closed))
+	defer ts.Close()
+	var tunnels atomic.Int64
+	socksAddr := serveFakeSOCKS5(t, &tunnels)
+	pac := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `function FindProxyForURL(url, host) {
+			return url.substring(0, 5) == "http:" ? "SOCKS5 %v" : "DIRECT";
+		}`, socksAddr)
+	}))
+	defer pac.Close()
+
+	setUpViperTestConfiguration()
+	viper.Set("pacScriptURL", pac.URL)
+	viper.Set("urlCheckerPlugins", []string{"urlcheck-pac"})
+
+	res := NewURLCheckerClient().CheckURL(context.Background(), webSocketURLOf(ts, "ws", "/socket"))
+	assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	assert.Positive(t, tunnels.Load())
+	require.Len(t, res.CheckerTrace, 1)
+	assert.Equal(t, "socks5://"+socksAddr, res.CheckerTrace[0].Proxy)
+}
+
+func TestWebSocketHandshakesAreRateLimitedPerDomain(t *testing.T) {
+	closed := make(chan struct{}, 10)
+	ts := httptest.NewServer(webSocketHandler("", closed))
+	defer ts.Close()
+	setUpViperTestConfiguration()
+	c := NewDomainRateLimitedChecker(5)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		res := c.CheckURL(context.Background(), webSocketURLOf(ts, "ws", "/socket"))
+		assert.Equal(t, Ok, res.Status, "%v", res.Error)
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 350*time.Millisecond)
+}
+
+func TestHandshakeURLs(t *testing.T) {
+	assert.Equal(t, "http://example.com/socket?a=1", handshakeURLOf("ws://example.com/socket?a=1"))
+	assert.Equal(t, "https://example.com:8443/", handshakeURLOf("WSS://example.com:8443/"))
+	assert.Equal(t, "https://example.com/", handshakeURLOf("https://example.com/"))
+	assert.Equal(t, "example.com:443", normalizeAddressOf("wss://example.com"))
+}
+
+func TestWebSocketAccept(t *testing.T) {
+	// the sample handshake of RFC 6455
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", webSocketAcceptOf("dGhlIHNhbXBsZSBub25jZQ=="))
+}
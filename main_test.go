@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -515,6 +516,39 @@ func TestEmptyRequestsShouldFail(t *testing.T) {
 	assert.Contains(t, strings.ToLower(body), "no")
 }
 
+func TestReadinessReflectsThePACScript(t *testing.T) {
+	setUpViperTestConfiguration()
+	pacScript := filepath.Join(t.TempDir(), "proxy.pac")
+	viper.Set("pacScriptURL", "file://"+pacScript)
+	viper.Set("urlCheckerPlugins", []string{"urlcheck-pac"})
+	t.Cleanup(func() {
+		viper.Set("pacScriptURL", "")
+		viper.Set("urlCheckerPlugins", []string{})
+	})
+
+	readiness := func() (int, server.ReadinessResponse) {
+		testServer := server.NewServer()
+		router := testServer.Detail()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		var res server.ReadinessResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+		return w.Code, res
+	}
+
+	code, res := readiness()
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "DOWN", res.Status)
+	assert.NotEmpty(t, res.PAC.LastError)
+
+	assert.NoError(t, os.WriteFile(pacScript, []byte(`function FindProxyForURL(url, host) { return "DIRECT"; }`), 0o600))
+	code, res = readiness()
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "UP", res.Status)
+	assert.True(t, res.PAC.Loaded)
+	assert.NotEmpty(t, res.PAC.LastRefresh)
+}
+
 func TestCloseNotifyRecorder_closeSignalsChannel(t *testing.T) {
 	w := newCloseNotifyRecorder()
 	w.close()
@@ -23,6 +23,44 @@ type URLCheckTraceResponse struct {
 	Code      int    `json:"code"`
 	ElapsedMs int64  `json:"elapsed_ms"`
 	Error     string `json:"error,omitempty"`
+	Attempt   int    `json:"attempt,omitempty"`
+	// BytesTransferred is the number of response body bytes read
+	BytesTransferred int64 `json:"bytes_transferred,omitempty"`
+	// Proxy is the proxy used, or DIRECT if chosen via the PAC script
+	Proxy string `json:"proxy,omitempty"`
+	// Timing breaks down the duration of the request the attempt's result is based on
+	Timing *TimingResponse `json:"timing,omitempty"`
+}
+
+// TimingResponse breaks down the duration of a request in milliseconds. Phases that did not happen,
+// e.g. connecting on a kept-alive connection, are 0.
+type TimingResponse struct {
+	DNSMs             float64 `json:"dns_ms"`
+	ConnectMs         float64 `json:"connect_ms"`
+	TLSMs             float64 `json:"tls_ms"`
+	TimeToFirstByteMs float64 `json:"ttfb_ms"`
+	TotalMs           float64 `json:"total_ms"`
+}
+
+// URLRedirectResponse reflects a single hop of a redirect chain
+type URLRedirectResponse struct {
+	URL       string `json:"url"`
+	Code      int    `json:"code"`
+	Location  string `json:"location"`
+	ElapsedMs int64  `json:"elapsed_ms"`
+	Permanent bool   `json:"permanent"`
+}
+
+// TLSResponse reflects the inspected peer certificate of an HTTPS check
+type TLSResponse struct {
+	Version         string   `json:"version,omitempty"`
+	Subject         string   `json:"subject"`
+	Issuer          string   `json:"issuer"`
+	DNSNames        []string `json:"dns_names"`
+	HostnameMatch   bool     `json:"hostname_match"`
+	NotAfter        int64    `json:"not_after"`
+	DaysUntilExpiry int      `json:"days_until_expiry"`
+	ChainError      string   `json:"chain_error,omitempty"`
 }
 
 // URLStatusResponse is the JSON response structure for one URL
@@ -39,12 +77,41 @@ type URLStatusResponse struct {
 	FetchedAtEpochSeconds int64 `json:"timestamp"`
 	// BodyPatternsFound will be filled with the configured regex patterns found in the response body
 	BodyPatternsFound []string `json:"body_patterns_found"`
+	// BodyBytesInspected is the number of response body bytes searched for the body patterns
+	BodyBytesInspected int64 `json:"body_bytes_inspected,omitempty"`
 	// RemoteAddr is filled with the resolved address when `enableRequestTracing` is configured
 	RemoteAddr string `json:"remote_addr,omitempty"`
 	// CheckTrace is a trace of the url checker plugin responses
 	CheckTrace []URLCheckTraceResponse `json:"check_trace"`
 	// ElapsedMs is the total duration in milliseconds of the uncached URL check
 	ElapsedMs int64 `json:"elapsed_ms"`
+	// AvailableAnchors lists the anchors found in the document when the status is `broken_anchor`
+	AvailableAnchors []string `json:"available_anchors,omitempty"`
+	// Redirects is the redirect chain followed, if any
+	Redirects []URLRedirectResponse `json:"redirects,omitempty"`
+	// FinalURL is the URL reached after following the redirects
+	FinalURL string `json:"final_url,omitempty"`
+	// TLS is filled with the peer certificate details when `inspectCertificates` is configured
+	TLS *TLSResponse `json:"tls,omitempty"`
+	// Warnings do not make the link broken, but might require attention, e.g. an expiring certificate
+	Warnings []string `json:"warnings,omitempty"`
+	// SkipReason is a machine-readable reason for the `skipped` status, e.g. `unsupported_scheme`
+	SkipReason string `json:"skip_reason,omitempty"`
+	// ErrorCategory classifies failures without a response, e.g. `dns_not_found`, `connection_refused` or `timeout_read`
+	ErrorCategory string `json:"error_category,omitempty"`
+	// Policy is the name of the check policy applied to the URL, if any
+	Policy string `json:"policy,omitempty"`
+	// Headers holds the response headers allow-listed via `captureResponseHeaders`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timing breaks down the duration of the request the result is based on
+	Timing *TimingResponse `json:"timing,omitempty"`
+	// Revalidated is true if an expired cached result was confirmed by a 304 Not Modified response
+	Revalidated bool `json:"revalidated,omitempty"`
+	// ContentHash is the hash of the normalized content, if content changes are detected
+	ContentHash         string `json:"content_hash,omitempty"`
+	PreviousContentHash string `json:"previous_content_hash,omitempty"`
+	// ContentChanged compares the content hash to the one of the previous check, nil if there was none
+	ContentChanged *bool `json:"content_changed,omitempty"`
 }
 
 // CheckURLsResponse is a JSON structure for the bulk URL check response
@@ -52,3 +119,20 @@ type CheckURLsResponse struct {
 	Urls   []URLStatusResponse `json:"urls"`
 	Result string              `json:"result"`
 }
+
+// ReadinessResponse is the JSON structure of the readiness probe
+type ReadinessResponse struct {
+	Status string             `json:"status"`
+	PAC    *PACStatusResponse `json:"pac,omitempty"`
+}
+
+// PACStatusResponse reflects the health of the PAC script
+type PACStatusResponse struct {
+	Source string `json:"source"`
+	// Loaded is true if a valid version of the PAC script is in use
+	Loaded bool `json:"loaded"`
+	// LastRefresh is the time the PAC script was last fetched successfully in the RFC 3339 format
+	LastRefresh string `json:"last_refresh,omitempty"`
+	// LastError is the error of the last refresh, while the last good version of the PAC script stays in use
+	LastError string `json:"last_error,omitempty"`
+}
@@ -163,7 +163,7 @@ func (s *Server) setupRoutes() {
 	statsRoutes.GET("/domains", s.getDomainStats)
 
 	s.server.GET("/livez", s.getHealthStatus)
-	s.server.GET("/readyz", s.getHealthStatus)
+	s.server.GET("/readyz", s.getReadiness)
 }
 
 func (s *Server) checkURLs(c *gin.Context) {
@@ -342,13 +342,61 @@ func (s *Server) checkURL(ctx context.Context, url URLRequest) URLStatusResponse
 		Error:                 errorString,
 		FetchedAtEpochSeconds: checkResult.FetchedAtEpochSeconds,
 		BodyPatternsFound:     checkResult.BodyPatternsFound,
+		BodyBytesInspected:    checkResult.BodyBytesInspected,
 		RemoteAddr:            checkResult.RemoteAddr,
 		CheckTrace:            translateCheckerTrace(checkResult.CheckerTrace),
 		ElapsedMs:             checkResult.ElapsedMs,
+		AvailableAnchors:      checkResult.AvailableAnchors,
+		Redirects:             translateRedirects(checkResult.Redirects),
+		FinalURL:              checkResult.FinalURL,
+		TLS:                   translateTLSInfo(checkResult.TLS),
+		Warnings:              checkResult.Warnings,
+		SkipReason:            checkResult.SkipReason,
+		ErrorCategory:         string(checkResult.ErrorCategory),
+		Policy:                checkResult.Policy,
+		Headers:               checkResult.Headers,
+		Timing:                translateTiming(checkResult.Timing),
+		Revalidated:           checkResult.Revalidated,
+		ContentHash:           checkResult.ContentHash,
+		PreviousContentHash:   checkResult.PreviousContentHash,
+		ContentChanged:        contentChangedOf(checkResult),
 	}
 	return urlStatus
 }
 
+func translateTLSInfo(info *infrastructure.TLSInfo) *TLSResponse {
+	if info == nil {
+		return nil
+	}
+	return &TLSResponse{
+		Version:         info.Version,
+		Subject:         info.Subject,
+		Issuer:          info.Issuer,
+		DNSNames:        info.DNSNames,
+		HostnameMatch:   info.HostnameMatch,
+		NotAfter:        info.NotAfter.Unix(),
+		DaysUntilExpiry: info.DaysUntilExpiry,
+		ChainError:      info.ChainError,
+	}
+}
+
+func translateRedirects(redirects []infrastructure.URLRedirect) []URLRedirectResponse {
+	if len(redirects) == 0 {
+		return nil
+	}
+	res := make([]URLRedirectResponse, 0, len(redirects))
+	for _, r := range redirects {
+		res = append(res, URLRedirectResponse{
+			URL:       r.URL,
+			Code:      r.Code,
+			Location:  r.Location,
+			ElapsedMs: r.ElapsedMs,
+			Permanent: r.Permanent,
+		})
+	}
+	return res
+}
+
 func translateCheckerTrace(trace []infrastructure.URLCheckerPluginTrace) []URLCheckTraceResponse {
 	res := make([]URLCheckTraceResponse, 0, len(trace))
 	for _, traceRes := range trace {
@@ -357,11 +405,42 @@ func translateCheckerTrace(trace []infrastructure.URLCheckerPluginTrace) []URLCh
 			Code:      traceRes.Code,
 			ElapsedMs: traceRes.ElapsedMs,
 			Error:     traceRes.Error,
+			Attempt:   traceRes.Attempt,
+
+			BytesTransferred: traceRes.BytesTransferred,
+			Proxy:            traceRes.Proxy,
+			Timing:           translateTiming(traceRes.Timing),
 		})
 	}
 	return res
 }
 
+func contentChangedOf(checkResult *infrastructure.URLCheckResult) *bool {
+	if checkResult.PreviousContentHash == "" {
+		return nil
+	}
+	changed := checkResult.ContentChanged
+	return &changed
+}
+
+func translateTiming(timing *infrastructure.RequestTiming) *TimingResponse {
+	if timing == nil {
+		return nil
+	}
+	return &TimingResponse{
+		DNSMs:             millisecondsOf(timing.DNS),
+		ConnectMs:         millisecondsOf(timing.Connect),
+		TLSMs:             millisecondsOf(timing.TLS),
+		TimeToFirstByteMs: millisecondsOf(timing.TimeToFirstByte),
+		TotalMs:           millisecondsOf(timing.Total),
+	}
+}
+
+// millisecondsOf rounds to microseconds, as local phases often take less than a millisecond
+func millisecondsOf(d time.Duration) float64 {
+	return float64(d.Round(time.Microsecond)) / float64(time.Millisecond)
+}
+
 func urlBlacklisted(url URLRequest) URLStatusResponse {
 	infrastructure.GlobalStats().OnLinkSkipped(infrastructure.DomainOf(url.URL))
 	return URLStatusResponse{
@@ -371,6 +450,7 @@ func urlBlacklisted(url URLRequest) URLStatusResponse {
 		Error:                 "url was blacklisted",
 		FetchedAtEpochSeconds: time.Now().Unix(),
 		BodyPatternsFound:     []string{},
+		SkipReason:            "blacklisted",
 	}
 }
 
@@ -461,6 +541,37 @@ func (s *Server) getHealthStatus(c *gin.Context) {
 	})
 }
 
+// getReadiness reports the service as not ready until the configured PAC script has been loaded
+func (s *Server) getReadiness(c *gin.Context) {
+	pac := s.urlChecker.PACStatus()
+	if pac == nil {
+		s.getHealthStatus(c)
+		return
+	}
+	status := "UP"
+	code := http.StatusOK
+	if !pac.Loaded {
+		status = "DOWN"
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, ReadinessResponse{
+		Status: status,
+		PAC:    translatePACStatus(pac),
+	})
+}
+
+func translatePACStatus(pac *infrastructure.PACStatus) *PACStatusResponse {
+	res := &PACStatusResponse{
+		Source:    pac.Source,
+		Loaded:    pac.Loaded,
+		LastError: pac.LastError,
+	}
+	if !pac.LastRefresh.IsZero() {
+		res.LastRefresh = pac.LastRefresh.UTC().Format(time.RFC3339)
+	}
+	return res
+}
+
 const instanceIdHeader = "X-INSTANCE-ID"
 const runningSinceHeader = "X-RUNNING-SINCE"
 